@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os/signal"
+	"syscall"
+)
+
+var (
+	// errOrphans is returned by the check command when orphaned reviews are found.
+	errOrphans = errors.New("orphaned reviews found, run the repair command to move them to the trash")
+	// errDuplicates is returned by the check command when duplicated reviews are found.
+	errDuplicates = errors.New("duplicated reviews found, run the repair command to delete them")
+)
+
+// check runs the consistency checks of the check and repair commands and closes the connections of the application,
+// the repair command moves the orphans found to the trash, deletes the duplicates and then creates the indexes. The
+// check is cancelled on SIGINT and SIGTERM.
+func (app *Application) check(repair bool) error {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	err := app.checkReviews(ctx, repair)
+	if dupErr := app.checkDuplicates(ctx, repair); dupErr != nil && err == nil {
+		err = dupErr
+	}
+	if err == nil && repair {
+		err = app.createIndexes(ctx)
+	}
+	if closeErr := app.close(context.Background()); closeErr != nil && err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// checkReviews reports the orphaned reviews, the reviews not in the trash whose place doesn't exist or is in the
+// trash, ie. the reviews left behind by a failed cascade of a place deletion. The orphans are moved to the trash when
+// repair is set, otherwise errOrphans is returned when any is found.
+func (app *Application) checkReviews(ctx context.Context, repair bool) error {
+	orphans, err := app.Models.Review.Orphans(ctx, app.Config.DB.Database, app.Config.DB.Collections.Reviews, repair)
+	if err != nil {
+		return err
+	}
+	for _, review := range *orphans {
+		app.Logger.WithField("review_id", review.ID).WithField("place_id", review.PlaceID).Warn("orphaned review")
+	}
+	app.Logger.WithField("orphans", len(*orphans)).WithField("repaired", repair).Info("reviews consistency check done")
+	if len(*orphans) > 0 && !repair {
+		return errOrphans
+	}
+	return nil
+}
+
+// checkDuplicates reports the duplicated reviews, the reviews of a user on a place but the one kept, ie. the reviews
+// created before the unique index of the reviews. The duplicates are deleted when repair is set, otherwise
+// errDuplicates is returned when any is found.
+func (app *Application) checkDuplicates(ctx context.Context, repair bool) error {
+	duplicates, err := app.Models.Review.Duplicates(ctx, app.Config.DB.Database, app.Config.DB.Collections.Reviews, repair)
+	if err != nil {
+		return err
+	}
+	for _, review := range *duplicates {
+		app.Logger.WithField("review_id", review.ID).WithField("place_id", review.PlaceID).WithField("user_id", review.UserID).Warn("duplicated review")
+	}
+	app.Logger.WithField("duplicates", len(*duplicates)).WithField("repaired", repair).Info("reviews uniqueness check done")
+	if len(*duplicates) > 0 && !repair {
+		return errDuplicates
+	}
+	return nil
+}
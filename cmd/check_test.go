@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/evansopilo/trouver/internal/data"
+)
+
+func TestCheckReviews(t *testing.T) {
+	ts := newTestServer(t)
+	ctx := context.Background()
+	db, places, reviews := ts.app.Config.DB.Database, ts.app.Config.DB.Collections.Places, ts.app.Config.DB.Collections.Reviews
+
+	// the place model of the legacy store didn't move the reviews to the trash with their place.
+	store := data.NewMemoryStore()
+	legacy := data.NewMemoryPlaceModel(store, "legacy")
+	ts.app.Models.Place = data.NewMemoryPlaceModel(store, reviews)
+	ts.app.Models.Review = data.NewMemoryReviewModel(store, places, ts.app.Config.DB.Collections.Revisions)
+
+	for _, id := range []string{"place-1", "place-2", "place-3"} {
+		ts.insertPlace(t, newPlace(id, "owner", "Java House"))
+		ts.insertReview(t, data.Review{ID: "review-" + id[len(id)-1:], PlaceID: id, UserID: "author", Rating: 4})
+	}
+	if err := ts.app.checkReviews(ctx, false); err != nil {
+		t.Fatalf("got error %v; want none", err)
+	}
+
+	// review-2 is left behind by its place in the trash and review-3 by its purged place.
+	if err := legacy.DeleteOne(ctx, db, places, "place-3", 1, "owner"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := legacy.Purge(ctx, db, places, time.Now().Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if err := legacy.DeleteOne(ctx, db, places, "place-2", 1, "owner"); err != nil {
+		t.Fatal(err)
+	}
+
+	orphans, err := ts.app.Models.Review.Orphans(ctx, db, reviews, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, review := range *orphans {
+		ids = append(ids, review.ID)
+	}
+	if !equalStrings(ids, []string{"review-2", "review-3"}) {
+		t.Fatalf("got orphans %v; want [review-2 review-3]", ids)
+	}
+	if err := ts.app.checkReviews(ctx, false); !errors.Is(err, errOrphans) {
+		t.Fatalf("check: got error %v; want %v", err, errOrphans)
+	}
+
+	if err := ts.app.checkReviews(ctx, true); err != nil {
+		t.Fatalf("repair: got error %v; want none", err)
+	}
+	if err := ts.app.checkReviews(ctx, false); err != nil {
+		t.Fatalf("check repaired: got error %v; want none", err)
+	}
+	if _, err := ts.app.Models.Review.FindOne(ctx, db, reviews, "review-1"); err != nil {
+		t.Errorf("got error %v finding the review of a live place; want none", err)
+	}
+
+	// the repaired orphan of a place in the trash is restored with it.
+	if err := ts.app.Models.Place.Restore(ctx, db, places, "place-2"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ts.app.Models.Review.FindOne(ctx, db, reviews, "review-2"); err != nil {
+		t.Errorf("got error %v finding the review of the restored place; want none", err)
+	}
+	if _, err := ts.app.Models.Review.FindOne(ctx, db, reviews, "review-3"); !errors.Is(err, data.ErrNoDocument) {
+		t.Errorf("got error %v finding the review of the purged place; want %v", err, data.ErrNoDocument)
+	}
+}
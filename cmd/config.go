@@ -1,14 +1,257 @@
 package main
 
 import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
 )
 
-func loadConfig(cfg *Config) *Config {
-	cfg.Version = os.Getenv("version")
-	cfg.App = os.Getenv("app")
-	cfg.Server.Port = os.Getenv("port")
-	cfg.Server.Env = os.Getenv("env")
-	cfg.DB.DSN = os.Getenv("dsn")
+// envPrefix is the prefix of the environment variables overriding the settings, the variable of a setting is the
+// prefix followed by the setting name in upper case with the dots replaced by underscores, e.g. the "db.dsn"
+// setting is read from TROUVER_DB_DSN.
+const envPrefix = "TROUVER_"
+
+// defaultConfig returns the configuration settings used when they are not set in the config file, the environment
+// or the command-line flags.
+func defaultConfig() Config {
+	var cfg Config
+	cfg.Version = "dev"
+	cfg.App = "trouver"
+	cfg.Server.Port = "8080"
+	cfg.Server.Env = "development"
+	cfg.Server.ShutdownTimeout = 30 * time.Second
+	cfg.Server.DrainDelay = 5 * time.Second
+	cfg.DB.Driver = "mongo"
+	cfg.DB.Database = "trouver"
+	cfg.DB.Collections.Places = "places"
+	cfg.DB.Collections.Reviews = "reviews"
+	cfg.DB.Collections.Revisions = "review_revisions"
+	cfg.Auth.Provider = "firebase"
+	cfg.Limiter.Enabled = true
+	cfg.Limiter.RPS = 10
+	cfg.Limiter.Burst = 20
+	cfg.Limiter.WriteRPS = 1
+	cfg.Limiter.WriteBurst = 5
+	cfg.Limiter.AuthRPS = 10
+	cfg.Limiter.AuthBurst = 20
+	cfg.Timeouts.Default = 5 * time.Second
+	cfg.Trash.Retention = 30 * 24 * time.Hour
+	cfg.Trash.PurgeInterval = time.Hour
+	cfg.Tracing.Exporter = "none"
+	cfg.Tracing.Endpoint = "localhost:4318"
+	cfg.Tracing.SampleRatio = 1
 	return cfg
 }
+
+// loadConfig loads the configuration settings, takes the command-line arguments. The defaults are overridden by
+// the config file given by the -config flag or the TROUVER_CONFIG environment variable, which are overridden by
+// the environment variables, which are overridden by the command-line flags. The loaded settings are validated and
+// all the problems found are reported in the returned error.
+func loadConfig(args []string) (Config, error) {
+	cfg := defaultConfig()
+
+	fs := flag.NewFlagSet("trouver", flag.ContinueOnError)
+	path := fs.String("config", os.Getenv(envPrefix+"CONFIG"), "path to the YAML or TOML config file")
+	fs.StringVar(&cfg.Version, "version", cfg.Version, "application version")
+	fs.StringVar(&cfg.App, "app", cfg.App, "application name")
+	fs.StringVar(&cfg.Server.Port, "server.port", cfg.Server.Port, "HTTP server port")
+	fs.StringVar(&cfg.Server.Env, "server.env", cfg.Server.Env, "environment (development|testing|staging|production)")
+	fs.DurationVar(&cfg.Server.ShutdownTimeout, "server.shutdown_timeout", cfg.Server.ShutdownTimeout, "time allowed to drain the in-flight requests on shutdown")
+	fs.DurationVar(&cfg.Server.DrainDelay, "server.drain_delay", cfg.Server.DrainDelay, "time the server is reported unavailable before it stops listening on shutdown")
+	fs.StringVar(&cfg.DB.Driver, "db.driver", cfg.DB.Driver, "database driver (mongo|memory)")
+	fs.StringVar(&cfg.DB.DSN, "db.dsn", cfg.DB.DSN, "MongoDB connection string")
+	fs.StringVar(&cfg.DB.Database, "db.database", cfg.DB.Database, "database name")
+	fs.StringVar(&cfg.DB.Collections.Places, "db.collections.places", cfg.DB.Collections.Places, "places collection name")
+	fs.StringVar(&cfg.DB.Collections.Reviews, "db.collections.reviews", cfg.DB.Collections.Reviews, "reviews collection name")
+	fs.StringVar(&cfg.DB.Collections.Revisions, "db.collections.revisions", cfg.DB.Collections.Revisions, "review revisions collection name")
+	fs.StringVar(&cfg.Auth.Provider, "auth.provider", cfg.Auth.Provider, "auth provider (firebase|jwt)")
+	fs.StringVar(&cfg.Auth.JWT.Secret, "auth.jwt.secret", cfg.Auth.JWT.Secret, "HS256 token secret")
+	fs.StringVar(&cfg.Auth.JWT.PublicKeyFile, "auth.jwt.public_key_file", cfg.Auth.JWT.PublicKeyFile, "PEM encoded RS256 or ES256 public key file")
+	fs.StringVar(&cfg.Auth.JWT.JWKSFile, "auth.jwt.jwks_file", cfg.Auth.JWT.JWKSFile, "JSON web key set file")
+	fs.StringVar(&cfg.Auth.JWT.Issuer, "auth.jwt.issuer", cfg.Auth.JWT.Issuer, "expected token issuer")
+	fs.StringVar(&cfg.Auth.JWT.Audience, "auth.jwt.audience", cfg.Auth.JWT.Audience, "expected token audience")
+	fs.StringVar(&cfg.Cursor.Secret, "cursor.secret", cfg.Cursor.Secret, "pagination cursor signing secret")
+	fs.BoolVar(&cfg.Limiter.Enabled, "limiter.enabled", cfg.Limiter.Enabled, "enable rate limiting")
+	fs.Float64Var(&cfg.Limiter.RPS, "limiter.rps", cfg.Limiter.RPS, "read requests per second per client")
+	fs.IntVar(&cfg.Limiter.Burst, "limiter.burst", cfg.Limiter.Burst, "read requests burst per client")
+	fs.Float64Var(&cfg.Limiter.WriteRPS, "limiter.write_rps", cfg.Limiter.WriteRPS, "write requests per second per client")
+	fs.IntVar(&cfg.Limiter.WriteBurst, "limiter.write_burst", cfg.Limiter.WriteBurst, "write requests burst per client")
+	fs.Float64Var(&cfg.Limiter.AuthRPS, "limiter.auth_rps", cfg.Limiter.AuthRPS, "authenticated requests per second per IP address")
+	fs.IntVar(&cfg.Limiter.AuthBurst, "limiter.auth_burst", cfg.Limiter.AuthBurst, "authenticated requests burst per IP address")
+	fs.DurationVar(&cfg.Timeouts.Default, "timeouts.default", cfg.Timeouts.Default, "default deadline of the requests")
+	fs.DurationVar(&cfg.Trash.Retention, "trash.retention", cfg.Trash.Retention, "time the deleted places and reviews are kept in the trash")
+	fs.DurationVar(&cfg.Trash.PurgeInterval, "trash.purge_interval", cfg.Trash.PurgeInterval, "interval between the purges of the trash")
+	fs.StringVar(&cfg.Tracing.Exporter, "tracing.exporter", cfg.Tracing.Exporter, "trace exporter (none|stdout|otlp)")
+	fs.StringVar(&cfg.Tracing.Endpoint, "tracing.endpoint", cfg.Tracing.Endpoint, "OTLP/HTTP collector host and port")
+	fs.BoolVar(&cfg.Tracing.Insecure, "tracing.insecure", cfg.Tracing.Insecure, "disable TLS to reach the collector")
+	fs.Float64Var(&cfg.Tracing.SampleRatio, "tracing.sample_ratio", cfg.Tracing.SampleRatio, "ratio of the sampled traces")
+
+	if err := fs.Parse(args); err != nil {
+		return cfg, err
+	}
+
+	// remember the flags set on the command line, they are applied again once the config file and the environment
+	// variables are read as they take precedence over both.
+	flags := map[string]string{}
+	fs.Visit(func(f *flag.Flag) { flags[f.Name] = f.Value.String() })
+
+	if *path != "" {
+		if err := readConfigFile(*path, &cfg); err != nil {
+			return cfg, err
+		}
+	}
+
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		value, ok := os.LookupEnv(envName(f.Name))
+		if !ok || err != nil || f.Name == "config" {
+			return
+		}
+		if setErr := f.Value.Set(value); setErr != nil {
+			err = fmt.Errorf("invalid value %q for %s: %v", value, envName(f.Name), setErr)
+		}
+	})
+	if err != nil {
+		return cfg, err
+	}
+
+	for name, value := range flags {
+		if err := fs.Set(name, value); err != nil {
+			return cfg, err
+		}
+	}
+
+	return cfg, cfg.validate()
+}
+
+// readConfigFile decodes the YAML or TOML config file into the config, the format is chosen by the file extension.
+// Only the settings present in the file are overridden.
+func readConfigFile(path string, cfg *Config) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	switch ext := filepath.Ext(path); ext {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(b))
+		dec.KnownFields(true)
+		// an empty file is reported as io.EOF, it leaves the settings unchanged.
+		if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("config file %s: %w", path, err)
+		}
+	case ".toml":
+		md, err := toml.Decode(string(b), cfg)
+		if err != nil {
+			return fmt.Errorf("config file %s: %w", path, err)
+		}
+		if undecoded := md.Undecoded(); len(undecoded) > 0 {
+			return fmt.Errorf("config file %s: unknown setting %q", path, undecoded[0].String())
+		}
+	default:
+		return fmt.Errorf("config file %s: unsupported format %q, expected .yaml, .yml or .toml", path, ext)
+	}
+	return nil
+}
+
+// envName returns the environment variable of the setting.
+func envName(setting string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(setting, ".", "_"))
+}
+
+// ConfigError holds the problems found when validating the configuration, keyed by the setting name.
+type ConfigError map[string]string
+
+func (e ConfigError) Error() string {
+	settings := make([]string, 0, len(e))
+	for setting := range e {
+		settings = append(settings, setting)
+	}
+	sort.Strings(settings)
+
+	var b strings.Builder
+	b.WriteString("invalid configuration:")
+	for _, setting := range settings {
+		fmt.Fprintf(&b, "\n  %s: %s", setting, e[setting])
+	}
+	return b.String()
+}
+
+// validate checks the configuration settings, all the problems found are reported in a ConfigError.
+func (cfg Config) validate() error {
+	problems := ConfigError{}
+	check := func(ok bool, setting, message string) {
+		if !ok {
+			problems[setting] = message
+		}
+	}
+
+	port, err := strconv.Atoi(cfg.Server.Port)
+	check(err == nil && port > 0 && port < 65536, "server.port", "must be a number between 1 and 65535")
+	check(oneOf(cfg.Server.Env, "development", "testing", "staging", "production"), "server.env", "must be one of development, testing, staging or production")
+
+	check(cfg.Server.ShutdownTimeout > 0, "server.shutdown_timeout", "must be greater than zero")
+	check(cfg.Server.DrainDelay >= 0, "server.drain_delay", "must not be negative")
+
+	check(oneOf(cfg.DB.Driver, "mongo", "memory"), "db.driver", "must be either mongo or memory")
+	check(cfg.DB.Driver != "mongo" || cfg.DB.DSN != "", "db.dsn", "must be provided for the mongo driver")
+	check(cfg.DB.Database != "", "db.database", "must be provided")
+	check(cfg.DB.Collections.Places != "", "db.collections.places", "must be provided")
+	check(cfg.DB.Collections.Reviews != "", "db.collections.reviews", "must be provided")
+	check(cfg.DB.Collections.Places != cfg.DB.Collections.Reviews, "db.collections.reviews", "must be different from the places collection")
+	check(cfg.DB.Collections.Revisions != "", "db.collections.revisions", "must be provided")
+	check(cfg.DB.Collections.Revisions != cfg.DB.Collections.Places && cfg.DB.Collections.Revisions != cfg.DB.Collections.Reviews,
+		"db.collections.revisions", "must be different from the places and reviews collections")
+
+	check(oneOf(cfg.Auth.Provider, "firebase", "jwt"), "auth.provider", "must be either firebase or jwt")
+	jwt := cfg.Auth.JWT
+	check(cfg.Auth.Provider != "jwt" || jwt.Secret != "" || jwt.PublicKeyFile != "" || jwt.JWKSFile != "",
+		"auth.jwt", "a secret, public key file or jwks file must be provided for the jwt provider")
+	check(jwt.Secret == "" || jwt.PublicKeyFile == "", "auth.jwt", "a secret and a public key file can't both be provided")
+
+	if cfg.Limiter.Enabled {
+		check(cfg.Limiter.RPS > 0, "limiter.rps", "must be greater than zero")
+		check(cfg.Limiter.Burst > 0, "limiter.burst", "must be greater than zero")
+		check(cfg.Limiter.WriteRPS > 0, "limiter.write_rps", "must be greater than zero")
+		check(cfg.Limiter.WriteBurst > 0, "limiter.write_burst", "must be greater than zero")
+		check(cfg.Limiter.AuthRPS > 0, "limiter.auth_rps", "must be greater than zero")
+		check(cfg.Limiter.AuthBurst > 0, "limiter.auth_burst", "must be greater than zero")
+	}
+
+	check(cfg.Timeouts.Default > 0, "timeouts.default", "must be greater than zero")
+	for route, timeout := range cfg.Timeouts.Routes {
+		check(len(strings.Fields(route)) == 2, "timeouts.routes."+route, `must be keyed by the method and path of the route, ie. "GET /v1/api/places"`)
+		check(timeout > 0, "timeouts.routes."+route, "must be greater than zero")
+	}
+
+	check(cfg.Trash.Retention > 0, "trash.retention", "must be greater than zero")
+	check(cfg.Trash.PurgeInterval > 0, "trash.purge_interval", "must be greater than zero")
+
+	check(oneOf(cfg.Tracing.Exporter, "none", "stdout", "otlp"), "tracing.exporter", "must be one of none, stdout or otlp")
+	check(cfg.Tracing.Exporter != "otlp" || cfg.Tracing.Endpoint != "", "tracing.endpoint", "must be provided for the otlp exporter")
+	check(cfg.Tracing.SampleRatio >= 0 && cfg.Tracing.SampleRatio <= 1, "tracing.sample_ratio", "must be between 0 and 1")
+
+	if len(problems) > 0 {
+		return problems
+	}
+	return nil
+}
+
+func oneOf(value string, values ...string) bool {
+	for _, v := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
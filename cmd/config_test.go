@@ -0,0 +1,113 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfig(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		cfg, err := loadConfig([]string{"-db.dsn", "mongodb://localhost:27017"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.Server.Port != "8080" || cfg.DB.Database != "trouver" || cfg.DB.Collections.Places != "places" || cfg.DB.Collections.Reviews != "reviews" {
+			t.Errorf("unexpected defaults: %+v", cfg)
+		}
+	})
+
+	t.Run("yaml file", func(t *testing.T) {
+		path := writeConfigFile(t, "config.yaml", "server:\n  port: \"9000\"\n  shutdown_timeout: 1m\ndb:\n  driver: memory\n  database: test\n  collections:\n    places: p\nlimiter:\n  rps: 2.5\ntimeouts:\n  routes:\n    \"GET /v1/api/places/search\": 10s\n")
+		cfg, err := loadConfig([]string{"-config", path})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.Server.Port != "9000" || cfg.Server.ShutdownTimeout != time.Minute || cfg.DB.Driver != "memory" || cfg.DB.Database != "test" || cfg.DB.Collections.Places != "p" || cfg.Limiter.RPS != 2.5 {
+			t.Errorf("settings not read from the file: %+v", cfg)
+		}
+		if cfg.Timeouts.Routes["GET /v1/api/places/search"] != 10*time.Second {
+			t.Errorf("route timeouts not read from the file: %v", cfg.Timeouts.Routes)
+		}
+		if cfg.DB.Collections.Reviews != "reviews" || cfg.Limiter.Burst != 20 || cfg.Timeouts.Default != 5*time.Second {
+			t.Errorf("settings missing from the file are not the defaults: %+v", cfg)
+		}
+	})
+
+	t.Run("toml file", func(t *testing.T) {
+		path := writeConfigFile(t, "config.toml", "[server]\nport = \"9001\"\nshutdown_timeout = \"5s\"\n\n[db]\ndriver = \"memory\"\n\n[auth]\nprovider = \"jwt\"\n\n[auth.jwt]\nsecret = \"s\"\n")
+		t.Setenv("TROUVER_CONFIG", path)
+		cfg, err := loadConfig(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.Server.Port != "9001" || cfg.Server.ShutdownTimeout != 5*time.Second || cfg.Auth.Provider != "jwt" || cfg.Auth.JWT.Secret != "s" {
+			t.Errorf("settings not read from the file: %+v", cfg)
+		}
+	})
+
+	t.Run("unknown file setting", func(t *testing.T) {
+		path := writeConfigFile(t, "config.yaml", "server:\n  prot: \"9000\"\n")
+		if _, err := loadConfig([]string{"-config", path}); err == nil {
+			t.Error("got no error for an unknown setting")
+		}
+	})
+
+	t.Run("precedence", func(t *testing.T) {
+		path := writeConfigFile(t, "config.yaml", "server:\n  port: \"9000\"\n  env: staging\ndb:\n  driver: memory\n  database: file\n")
+		t.Setenv("TROUVER_SERVER_PORT", "9100")
+		t.Setenv("TROUVER_DB_DATABASE", "env")
+		cfg, err := loadConfig([]string{"-config", path, "-db.database", "flag"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.Server.Env != "staging" {
+			t.Errorf("got env %q; want the file value %q", cfg.Server.Env, "staging")
+		}
+		if cfg.Server.Port != "9100" {
+			t.Errorf("got port %q; want the environment value %q", cfg.Server.Port, "9100")
+		}
+		if cfg.DB.Database != "flag" {
+			t.Errorf("got database %q; want the flag value %q", cfg.DB.Database, "flag")
+		}
+	})
+
+	t.Run("invalid environment value", func(t *testing.T) {
+		t.Setenv("TROUVER_LIMITER_BURST", "many")
+		if _, err := loadConfig([]string{"-db.driver", "memory"}); err == nil {
+			t.Error("got no error for an invalid environment value")
+		}
+	})
+
+	t.Run("validation", func(t *testing.T) {
+		_, err := loadConfig([]string{"-server.port", "http", "-server.env", "dev", "-auth.provider", "jwt", "-tracing.exporter", "zipkin"})
+		var cfgErr ConfigError
+		if !errors.As(err, &cfgErr) {
+			t.Fatalf("got error %v; want a ConfigError", err)
+		}
+		for _, setting := range []string{"server.port", "server.env", "db.dsn", "auth.jwt", "tracing.exporter"} {
+			if _, ok := cfgErr[setting]; !ok {
+				t.Errorf("setting %q is not reported in %v", setting, cfgErr)
+			}
+		}
+	})
+
+	t.Run("secret and public key", func(t *testing.T) {
+		_, err := loadConfig([]string{"-db.driver", "memory", "-auth.provider", "jwt", "-auth.jwt.secret", "s", "-auth.jwt.public_key_file", "key.pem"})
+		var cfgErr ConfigError
+		if !errors.As(err, &cfgErr) || cfgErr["auth.jwt"] == "" {
+			t.Fatalf("got error %v; want auth.jwt reported", err)
+		}
+	})
+}
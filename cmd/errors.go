@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/evansopilo/trouver/internal/data"
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/gofiber/fiber/v2"
+)
+
+// problem is the RFC 7807 problem details document sent back to the client when a request fails, it is extended
+// with the id of the request and the validation errors mapping each failing field to its message.
+type problem struct {
+	Type      string            `json:"type"`
+	Title     string            `json:"title"`
+	Status    int               `json:"status"`
+	Detail    string            `json:"detail,omitempty"`
+	Instance  string            `json:"instance,omitempty"`
+	RequestID string            `json:"request_id,omitempty"`
+	Errors    map[string]string `json:"errors,omitempty"`
+}
+
+// ErrorHandler sends the error returned by the handlers and the middlewares back to the client as a problem details
+// document, with the status code of the error given by errorStatus. The detail of the unexpected errors is hidden
+// from the client and the error is logged instead.
+func (app *Application) ErrorHandler(c *fiber.Ctx, err error) error {
+	status := errorStatus(err)
+	requestID, _ := c.Locals("request_id").(string)
+	p := problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    errorDetail(err, status),
+		Instance:  c.Path(),
+		RequestID: requestID,
+	}
+
+	var errs validation.Errors
+	if errors.As(err, &errs) {
+		p.Errors = map[string]string{}
+		flattenErrors(p.Errors, "", errs)
+	}
+	if status >= fiber.StatusInternalServerError && status != fiber.StatusServiceUnavailable && status != fiber.StatusGatewayTimeout {
+		app.logger(c).Error(err)
+	}
+
+	c.Status(status)
+	if err := c.JSON(p); err != nil {
+		return err
+	}
+	c.Set(fiber.HeaderContentType, "application/problem+json")
+	return nil
+}
+
+// errorStatus maps the error to the status code of the response. The kinds of the model errors are mapped to
+// their status codes, the errors of the validation rules to a status unprocessable entity and the fiber errors
+// to their own code, any other error is an internal server error.
+func errorStatus(err error) int {
+	var e *fiber.Error
+	var errs validation.Errors
+	switch {
+	case errors.As(err, &e):
+		return e.Code
+	case errors.Is(err, context.DeadlineExceeded):
+		return fiber.StatusGatewayTimeout
+	case errors.Is(err, data.ErrNoDocument):
+		return fiber.StatusNotFound
+	case errors.Is(err, data.ErrConflict):
+		return fiber.StatusConflict
+	case errors.Is(err, data.ErrValidation), errors.As(err, &errs):
+		return fiber.StatusUnprocessableEntity
+	case errors.Is(err, data.ErrUnavailable):
+		return fiber.StatusServiceUnavailable
+	}
+	return fiber.StatusInternalServerError
+}
+
+// errorDetail returns the explanation of the error sent to the client. The messages of the fiber errors are meant
+// for the client, the other errors are described by their status code so that no internal detail is leaked.
+func errorDetail(err error, status int) string {
+	var e *fiber.Error
+	if errors.As(err, &e) {
+		return e.Message
+	}
+	switch status {
+	case fiber.StatusNotFound:
+		return "the requested resource could not be found"
+	case fiber.StatusConflict:
+		return "the resource conflicts with an existing resource"
+	case fiber.StatusUnprocessableEntity:
+		return "invalid request"
+	case fiber.StatusServiceUnavailable:
+		return "the service is temporarily unavailable, please retry later"
+	case fiber.StatusGatewayTimeout:
+		return "the request could not be completed in time"
+	}
+	return "the server encountered a problem and could not process the request"
+}
+
+// flattenErrors adds the messages of the nested validation errors to the fields map, keyed by their field path.
+// Nested fields are joined with a dot ie. "location.geo".
+func flattenErrors(fields map[string]string, prefix string, errs validation.Errors) {
+	for field, err := range errs {
+		if prefix != "" {
+			field = prefix + "." + field
+		}
+		if nested, ok := err.(validation.Errors); ok {
+			flattenErrors(fields, field, nested)
+			continue
+		}
+		fields[field] = err.Error()
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/evansopilo/trouver/internal/data"
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestErrorStatus(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"not found", &data.Error{Kind: data.ErrNoDocument, Op: "PlaceModel.FindOne"}, http.StatusNotFound},
+		{"conflict", &data.Error{Kind: data.ErrConflict, Op: "PlaceModel.InsertOne"}, http.StatusConflict},
+		{"validation", &data.Error{Kind: data.ErrValidation, Op: "PlaceModel.InsertOne"}, http.StatusUnprocessableEntity},
+		{"database unauthorized", &data.Error{Op: "PlaceModel.List", Err: mongo.CommandError{Code: 13, Name: "Unauthorized"}}, http.StatusInternalServerError},
+		{"unavailable", &data.Error{Kind: data.ErrUnavailable, Op: "PlaceModel.List"}, http.StatusServiceUnavailable},
+		{"deadline", &data.Error{Kind: data.ErrUnavailable, Op: "PlaceModel.List", Err: context.DeadlineExceeded}, http.StatusGatewayTimeout},
+		{"validation rules", validation.Errors{"title": errors.New("cannot be blank")}, http.StatusUnprocessableEntity},
+		{"fiber", fiber.NewError(http.StatusBadRequest, "invalid request"), http.StatusBadRequest},
+		{"unexpected", errors.New("boom"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := errorStatus(tt.err); got != tt.want {
+				t.Errorf("got status %d; want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestErrorHandler(t *testing.T) {
+	ts := newTestServer(t)
+	token := testToken(t, "admin", "admin")
+
+	tests := []struct {
+		method string
+		path   string
+		token  string
+		body   interface{}
+	}{
+		{http.MethodGet, "/v1/api/places/missing", "", nil},
+		{http.MethodPatch, "/v1/api/places/missing", token, map[string]interface{}{"title": "Cafe"}},
+		{http.MethodDelete, "/v1/api/places/missing", token, nil},
+		{http.MethodPatch, "/v1/api/reviews/missing", token, map[string]interface{}{"rating": 4}},
+		{http.MethodDelete, "/v1/api/reviews/missing", token, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			status, body := ts.do(t, tt.method, tt.path, tt.token, tt.body)
+			if status != http.StatusNotFound {
+				t.Fatalf("got status %d; want %d", status, http.StatusNotFound)
+			}
+			if body["status"] != float64(http.StatusNotFound) || body["title"] != "Not Found" || body["instance"] != tt.path {
+				t.Errorf("unexpected body %v", body)
+			}
+		})
+	}
+
+	res, err := ts.router.Test(httptest.NewRequest(http.MethodGet, "/v1/api/places/missing", nil), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if got := res.Header.Get(fiber.HeaderContentType); got != "application/problem+json" {
+		t.Errorf("got content type %q; want %q", got, "application/problem+json")
+	}
+}
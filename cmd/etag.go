@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/evansopilo/trouver/internal/data"
+	"github.com/gofiber/fiber/v2"
+)
+
+// errModified is the error of a request writing the document from a version the client read before another write.
+var errModified = fiber.NewError(fiber.StatusPreconditionFailed, "the resource was modified since it was read")
+
+// entityTag returns the entity tag of a document at the given version, it is a strong tag as the version of a
+// document changes on each write.
+func entityTag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// matchTag reports whether the entity tag is in the list of tags of an If-Match or If-None-Match header, "*"
+// matches any tag. With the weak comparison of If-None-Match a weak tag sent by the client matches, If-Match uses
+// the strong comparison.
+func matchTag(header, etag string, weak bool) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if weak {
+			tag = strings.TrimPrefix(tag, "W/")
+		}
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// notModified sets the entity tag of the document read by the request in the ETag header and reports whether the
+// client already has this version of the document, as given by its If-None-Match header. The caller responds with
+// a status not modified without a body when it does.
+func notModified(c *fiber.Ctx, version int64) bool {
+	etag := entityTag(version)
+	c.Set(fiber.HeaderETag, etag)
+	if header := c.Get(fiber.HeaderIfNoneMatch); header != "" && matchTag(header, etag, true) {
+		c.Status(fiber.StatusNotModified)
+		return true
+	}
+	return false
+}
+
+// checkIfMatch checks the If-Match header of a request writing the document, a status precondition failed error is
+// returned when the client sent a tag that is not the entity tag of the current version of the document. Requests
+// without If-Match are not checked.
+func checkIfMatch(c *fiber.Ctx, version int64) error {
+	header := c.Get(fiber.HeaderIfMatch)
+	if header == "" || matchTag(header, entityTag(version), false) {
+		return nil
+	}
+	return errModified
+}
+
+// ifMatchConflict returns the error of a write of the document from the version checked by checkIfMatch, the write
+// fails with a conflict when another request wrote the document in between. The client which sent If-Match gets a
+// status precondition failed as when the check fails, the other errors are returned as they are.
+func ifMatchConflict(c *fiber.Ctx, err error) error {
+	if errors.Is(err, data.ErrConflict) && c.Get(fiber.HeaderIfMatch) != "" {
+		return errModified
+	}
+	return err
+}
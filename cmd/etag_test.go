@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/evansopilo/trouver/internal/data"
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestPlaceETag(t *testing.T) {
+	ts := newTestServer(t)
+	ts.insertPlace(t, newPlace("place-1", "owner", "Java House"))
+	auth := "Bearer " + testToken(t, "owner", "")
+
+	status, header := ts.send(t, http.MethodGet, "/v1/api/places/place-1", "", nil)
+	if status != http.StatusOK || header.Get(fiber.HeaderETag) != `"1"` {
+		t.Fatalf("got status %d and etag %q; want %d and %q", status, header.Get(fiber.HeaderETag), http.StatusOK, `"1"`)
+	}
+	if status, _ := ts.send(t, http.MethodGet, "/v1/api/places/place-1", "", map[string]string{fiber.HeaderIfNoneMatch: `W/"1"`}); status != http.StatusNotModified {
+		t.Errorf("if-none-match: got status %d; want %d", status, http.StatusNotModified)
+	}
+
+	status, header = ts.send(t, http.MethodPatch, "/v1/api/places/place-1", `{"title": "Java House Westlands"}`, map[string]string{
+		fiber.HeaderAuthorization: auth,
+		fiber.HeaderIfMatch:       `"1"`,
+	})
+	if status != http.StatusOK || header.Get(fiber.HeaderETag) != `"2"` {
+		t.Fatalf("if-match: got status %d and etag %q; want %d and %q", status, header.Get(fiber.HeaderETag), http.StatusOK, `"2"`)
+	}
+
+	// the client still holding the first version can't overwrite the update.
+	if status, _ := ts.send(t, http.MethodPatch, "/v1/api/places/place-1", `{"title": "Stale"}`, map[string]string{
+		fiber.HeaderAuthorization: auth,
+		fiber.HeaderIfMatch:       `"1"`,
+	}); status != http.StatusPreconditionFailed {
+		t.Errorf("stale patch: got status %d; want %d", status, http.StatusPreconditionFailed)
+	}
+	if status, _ := ts.send(t, http.MethodDelete, "/v1/api/places/place-1", "", map[string]string{
+		fiber.HeaderAuthorization: auth,
+		fiber.HeaderIfMatch:       `"1"`,
+	}); status != http.StatusPreconditionFailed {
+		t.Errorf("stale delete: got status %d; want %d", status, http.StatusPreconditionFailed)
+	}
+	if status, _ := ts.send(t, http.MethodGet, "/v1/api/places/place-1", "", map[string]string{fiber.HeaderIfNoneMatch: `"1"`}); status != http.StatusOK {
+		t.Errorf("modified: got status %d; want %d", status, http.StatusOK)
+	}
+
+	// a new review updates the rating statistics of the place, which are derived from the reviews and don't change
+	// its version, the owner holding the version read before the review can still write the place.
+	ts.insertReview(t, data.Review{ID: "review-1", PlaceID: "place-1", UserID: "author", Rating: 4})
+	if _, header := ts.send(t, http.MethodGet, "/v1/api/places/place-1", "", nil); header.Get(fiber.HeaderETag) != `"2"` {
+		t.Errorf("got etag %q after a review; want %q", header.Get(fiber.HeaderETag), `"2"`)
+	}
+
+	if status, _ := ts.send(t, http.MethodDelete, "/v1/api/places/place-1", "", map[string]string{
+		fiber.HeaderAuthorization: auth,
+		fiber.HeaderIfMatch:       `"2"`,
+	}); status != http.StatusOK {
+		t.Errorf("delete: got status %d; want %d", status, http.StatusOK)
+	}
+}
+
+func TestReviewETag(t *testing.T) {
+	ts := newTestServer(t)
+	ts.insertPlace(t, newPlace("place-1", "owner", "Java House"))
+	ts.insertReview(t, data.Review{ID: "review-1", PlaceID: "place-1", UserID: "author", Rating: 2})
+	auth := "Bearer " + testToken(t, "author", "")
+
+	status, header := ts.send(t, http.MethodGet, "/v1/api/reviews/review-1", "", nil)
+	if status != http.StatusOK || header.Get(fiber.HeaderETag) != `"1"` {
+		t.Fatalf("got status %d and etag %q; want %d and %q", status, header.Get(fiber.HeaderETag), http.StatusOK, `"1"`)
+	}
+	if status, _ := ts.send(t, http.MethodGet, "/v1/api/reviews/review-1", "", map[string]string{fiber.HeaderIfNoneMatch: `"0", "1"`}); status != http.StatusNotModified {
+		t.Errorf("if-none-match: got status %d; want %d", status, http.StatusNotModified)
+	}
+	if status, _ := ts.send(t, http.MethodPatch, "/v1/api/reviews/review-1", `{"rating": 4}`, map[string]string{
+		fiber.HeaderAuthorization: auth,
+		fiber.HeaderIfMatch:       `W/"1"`,
+	}); status != http.StatusPreconditionFailed {
+		t.Errorf("weak if-match: got status %d; want %d", status, http.StatusPreconditionFailed)
+	}
+	if status, header := ts.send(t, http.MethodPatch, "/v1/api/reviews/review-1", `{"rating": 4}`, map[string]string{
+		fiber.HeaderAuthorization: auth,
+		fiber.HeaderIfMatch:       `"1"`,
+	}); status != http.StatusOK || header.Get(fiber.HeaderETag) != `"2"` {
+		t.Errorf("if-match: got status %d and etag %q; want %d and %q", status, header.Get(fiber.HeaderETag), http.StatusOK, `"2"`)
+	}
+	if status, _ := ts.send(t, http.MethodDelete, "/v1/api/reviews/review-1", "", map[string]string{
+		fiber.HeaderAuthorization: auth,
+		fiber.HeaderIfMatch:       `"1"`,
+	}); status != http.StatusPreconditionFailed {
+		t.Errorf("stale delete: got status %d; want %d", status, http.StatusPreconditionFailed)
+	}
+}
+
+// raceObserver runs the write of another request before the write of a place, as if it was sent between the read and
+// the write of a request.
+type raceObserver struct {
+	race func()
+}
+
+func (o *raceObserver) Observe(ctx context.Context, model, method string) (context.Context, func(err error)) {
+	if model == "PlaceModel" && (method == "UpdateOne" || method == "DeleteOne") && o.race != nil {
+		race := o.race
+		o.race = nil
+		race()
+	}
+	return ctx, func(err error) {}
+}
+
+func TestIfMatchRace(t *testing.T) {
+	ts := newTestServer(t)
+	ts.insertPlace(t, newPlace("place-1", "owner", "Java House"))
+	auth := "Bearer " + testToken(t, "owner", "")
+	models := ts.app.Models
+	observer := &raceObserver{}
+	ts.app.Models = data.Observe(ts.app.Models, observer)
+
+	// update writes the place as another request of the owner would, after the If-Match check of the request.
+	update := func(version int64, title string) func() {
+		return func() {
+			place := newPlace("place-1", "owner", title)
+			place.Version = version
+			if err := models.Place.UpdateOne(context.Background(), ts.app.Config.DB.Database, ts.app.Config.DB.Collections.Places, &place); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	observer.race = update(1, "Java House Westlands")
+	if status, _ := ts.send(t, http.MethodPatch, "/v1/api/places/place-1", `{"title": "Stale"}`, map[string]string{
+		fiber.HeaderAuthorization: auth,
+		fiber.HeaderIfMatch:       `"1"`,
+	}); status != http.StatusPreconditionFailed {
+		t.Errorf("patch: got status %d; want %d", status, http.StatusPreconditionFailed)
+	}
+	observer.race = update(2, "Java House Kilimani")
+	if status, _ := ts.send(t, http.MethodDelete, "/v1/api/places/place-1", "", map[string]string{
+		fiber.HeaderAuthorization: auth,
+		fiber.HeaderIfMatch:       `"2"`,
+	}); status != http.StatusPreconditionFailed {
+		t.Errorf("delete: got status %d; want %d", status, http.StatusPreconditionFailed)
+	}
+
+	// the client which didn't send If-Match gets a conflict.
+	observer.race = update(3, "Java House Karen")
+	if status, _ := ts.send(t, http.MethodDelete, "/v1/api/places/place-1", "", map[string]string{fiber.HeaderAuthorization: auth}); status != http.StatusConflict {
+		t.Errorf("delete without if-match: got status %d; want %d", status, http.StatusConflict)
+	}
+	if status, _ := ts.send(t, http.MethodGet, "/v1/api/places/place-1", "", nil); status != http.StatusOK {
+		t.Errorf("get: got status %d; want %d", status, http.StatusOK)
+	}
+
+	// a review written meanwhile doesn't change the version of the place.
+	observer.race = func() {
+		ts.insertReview(t, data.Review{ID: "review-1", PlaceID: "place-1", UserID: "author", Rating: 4})
+	}
+	if status, _ := ts.send(t, http.MethodPatch, "/v1/api/places/place-1", `{"title": "Java House"}`, map[string]string{
+		fiber.HeaderAuthorization: auth,
+		fiber.HeaderIfMatch:       `"4"`,
+	}); status != http.StatusOK {
+		t.Errorf("patch after a review: got status %d; want %d", status, http.StatusOK)
+	}
+}
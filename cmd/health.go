@@ -1,11 +1,24 @@
 package main
 
 import (
+	"context"
+	"sync"
+	"time"
+
 	"github.com/gofiber/fiber/v2"
 )
 
+// healthCheck is a check of a dependency of the application run by the readiness probe. The server is not ready
+// when a critical dependency is down, the other dependencies only degrade the service.
+type healthCheck struct {
+	name     string
+	critical bool
+	check    func(ctx context.Context) error
+}
+
 // Health hanlder gets application health status on '/health' endpoint with
-// status code 200 ok.
+// status code 200 ok. It is the liveness probe, it only reports that the
+// process is running and serving requests, see Ready for the dependencies.
 func (app *Application) Health(c *fiber.Ctx) error {
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"status":      "available",
@@ -14,3 +27,68 @@ func (app *Application) Health(c *fiber.Ctx) error {
 		"environment": app.Config.Server.Env,
 	})
 }
+
+// Ready handler gets application readiness status on '/health/ready' endpoint,
+// the dependencies are checked concurrently and reported with their status and
+// latency. Status code 503 service unavailable is returned when a critical
+// dependency is down or the server is shutting down, otherwise status code 200
+// ok with the status "degraded" when a non-critical dependency is down.
+func (app *Application) Ready(c *fiber.Ctx) error {
+
+	// create a context with a 2-second timeout deadline, so that a dependency that doesn't respond is reported as
+	// down before the probe of the orchestrator times out.
+	ctx, cancel := context.WithTimeout(c.UserContext(), 2*time.Second)
+	defer cancel()
+
+	type result struct {
+		Status    string  `json:"status"`
+		Critical  bool    `json:"critical"`
+		LatencyMS float64 `json:"latency_ms"`
+	}
+
+	logger := app.logger(c)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	checks := make(map[string]result, len(app.checks))
+	for _, hc := range app.checks {
+		wg.Add(1)
+		go func(hc healthCheck) {
+			defer wg.Done()
+			start := time.Now()
+			err := hc.check(ctx)
+			r := result{Status: "up", Critical: hc.critical, LatencyMS: float64(time.Since(start).Microseconds()) / 1000}
+			// the errors of the dependencies may tell their addresses, they are logged rather than sent back by the
+			// unauthenticated probe.
+			if err != nil {
+				r.Status = "down"
+				logger.WithField("check", hc.name).WithError(err).Warn("health check failed")
+			}
+			mu.Lock()
+			checks[hc.name] = r
+			mu.Unlock()
+		}(hc)
+	}
+	wg.Wait()
+
+	status, code := "available", fiber.StatusOK
+	for _, r := range checks {
+		if r.Status == "down" {
+			if r.Critical {
+				status, code = "unavailable", fiber.StatusServiceUnavailable
+				break
+			}
+			status = "degraded"
+		}
+	}
+	if app.draining.Load() {
+		status, code = "unavailable", fiber.StatusServiceUnavailable
+	}
+
+	return c.Status(code).JSON(fiber.Map{
+		"status":      status,
+		"app":         app.Config.App,
+		"version":     app.Config.Version,
+		"environment": app.Config.Server.Env,
+		"checks":      checks,
+	})
+}
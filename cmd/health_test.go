@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestHealth(t *testing.T) {
+	ts := newTestServer(t)
+
+	for _, path := range []string{"/v1/api/health", "/v1/api/health/live"} {
+		status, body := ts.do(t, http.MethodGet, path, "", nil)
+		if status != http.StatusOK {
+			t.Fatalf("%s: got status %d; want %d", path, status, http.StatusOK)
+		}
+		if body["status"] != "available" || body["version"] != "test" || body["environment"] != "testing" {
+			t.Errorf("%s: unexpected health body %v", path, body)
+		}
+	}
+
+	// the liveness probe doesn't depend on the readiness of the server.
+	ts.app.draining.Store(true)
+	if status, _ := ts.do(t, http.MethodGet, "/v1/api/health/live", "", nil); status != http.StatusOK {
+		t.Errorf("draining: got status %d; want %d", status, http.StatusOK)
+	}
+}
+
+func TestReady(t *testing.T) {
+	down := func(ctx context.Context) error { return errors.New("connection refused") }
+
+	tests := []struct {
+		name       string
+		check      *healthCheck
+		draining   bool
+		wantCode   int
+		wantStatus string
+	}{
+		{"all up", nil, false, http.StatusOK, "available"},
+		{"non-critical down", &healthCheck{name: "cache", check: down}, false, http.StatusOK, "degraded"},
+		{"critical down", &healthCheck{name: "queue", critical: true, check: down}, false, http.StatusServiceUnavailable, "unavailable"},
+		{"draining", nil, true, http.StatusServiceUnavailable, "unavailable"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t)
+			if tt.check != nil {
+				ts.app.checks = append(ts.app.checks, *tt.check)
+			}
+			ts.app.draining.Store(tt.draining)
+
+			status, body := ts.do(t, http.MethodGet, "/v1/api/health/ready", "", nil)
+			if status != tt.wantCode {
+				t.Fatalf("got status %d; want %d", status, tt.wantCode)
+			}
+			if body["status"] != tt.wantStatus {
+				t.Errorf("got health status %v; want %q", body["status"], tt.wantStatus)
+			}
+
+			checks, _ := body["checks"].(map[string]interface{})
+			for _, name := range []string{"database", "auth"} {
+				check, ok := checks[name].(map[string]interface{})
+				if !ok || check["status"] != "up" {
+					t.Errorf("check %q is not reported up: %v", name, checks)
+					continue
+				}
+				if _, ok := check["latency_ms"].(float64); !ok {
+					t.Errorf("check %q has no latency: %v", name, check)
+				}
+			}
+			if tt.check != nil {
+				check, _ := checks[tt.check.name].(map[string]interface{})
+				if check["status"] != "down" {
+					t.Errorf("check %q is not reported down: %v", tt.check.name, check)
+				}
+				if _, ok := check["error"]; ok {
+					t.Errorf("check %q reports its error to the client: %v", tt.check.name, check)
+				}
+			}
+		})
+	}
+}
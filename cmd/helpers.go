@@ -0,0 +1,180 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/evansopilo/trouver/internal/data"
+	"github.com/gofiber/fiber/v2"
+)
+
+// readPage reads the 'size' and either the 'cursor' or the 'page' pagination query parameters into the filter and
+// returns the page size. The filter limit is one document over the page size, so that the handlers can tell from
+// the extra document whether there are more pages.
+func (app *Application) readPage(c *fiber.Ctx, filter *data.Filter) (int, error) {
+	size, err := strconv.Atoi(c.Query("size", "10"))
+	if err != nil || size < 1 || size > 100 {
+		return 0, errors.New("size must be a number between 1 and 100")
+	}
+	filter.Limit = size + 1
+
+	if cursor := c.Query("cursor"); cursor != "" {
+		filter.After, err = data.DecodeCursor(cursor, []byte(app.Config.Cursor.Secret))
+		if err != nil {
+			return 0, err
+		}
+		return size, nil
+	}
+
+	page, err := strconv.Atoi(c.Query("page", "1"))
+	if err != nil || page < 1 {
+		return 0, errors.New("page must be a positive number")
+	}
+	filter.Skip = (page - 1) * size
+	return size, nil
+}
+
+// nextCursor encodes the cursor of the next page starting after the document with the given creation time and id.
+func (app *Application) nextCursor(createdAt time.Time, id string) string {
+	return data.Cursor{CreatedAt: createdAt, ID: id}.Encode([]byte(app.Config.Cursor.Secret))
+}
+
+// readList reads a comma separated list query parameter ie. 'category=coffee,bakery', empty items are skipped.
+func readList(c *fiber.Ctx, key string) []string {
+	var list []string
+	for _, item := range strings.Split(c.Query(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// readGeoQuery reads the geo query of the nearby places from the query parameters. The near point is given by the
+// 'lat' and 'lng' parameters with an optional 'radius' in meters, the bounding box by 'bbox=minLng,minLat,maxLng,maxLat'
+// and the polygon by 'polygon=lng,lat;lng,lat;lng,lat'. At least one of the point, box or polygon is required.
+func readGeoQuery(c *fiber.Ctx) (data.GeoQuery, error) {
+	var query data.GeoQuery
+
+	if c.Query("lat") != "" || c.Query("lng") != "" {
+		lat, err := strconv.ParseFloat(c.Query("lat"), 64)
+		if err != nil || lat < -90 || lat > 90 {
+			return query, errors.New("lat must be a latitude between -90 and 90")
+		}
+		lng, err := strconv.ParseFloat(c.Query("lng"), 64)
+		if err != nil || lng < -180 || lng > 180 {
+			return query, errors.New("lng must be a longitude between -180 and 180")
+		}
+		query.Near = []float64{lng, lat}
+
+		if radius := c.Query("radius"); radius != "" {
+			query.MaxDistance, err = strconv.ParseFloat(radius, 64)
+			if err != nil || query.MaxDistance <= 0 {
+				return query, errors.New("radius must be a positive distance in meters")
+			}
+		}
+	}
+
+	if bbox := c.Query("bbox"); bbox != "" {
+		box, err := parsePoint(bbox, 4)
+		if err != nil || !validPoint(box[0:2]) || !validPoint(box[2:4]) || box[0] >= box[2] || box[1] >= box[3] {
+			return query, errors.New("bbox must be minLng,minLat,maxLng,maxLat")
+		}
+		// the edges of a GeoJSON polygon are the shortest path between its points, a box as wide as a hemisphere
+		// is ambiguous and rejected by the geospatial query.
+		if box[2]-box[0] >= 180 {
+			return query, errors.New("bbox must be less than 180 degrees of longitude wide")
+		}
+		query.Box = box
+	}
+
+	if polygon := c.Query("polygon"); polygon != "" {
+		if query.Box != nil {
+			return query, errors.New("bbox and polygon can't be used together")
+		}
+		for _, p := range strings.Split(polygon, ";") {
+			point, err := parsePoint(p, 2)
+			if err != nil || !validPoint(point) {
+				return query, errors.New("polygon must be a list of lng,lat points separated by ';'")
+			}
+			query.Polygon = append(query.Polygon, point)
+		}
+		if len(query.Polygon) < 3 {
+			return query, errors.New("polygon must have at least 3 points")
+		}
+	}
+
+	if query.Near == nil && query.Box == nil && query.Polygon == nil {
+		return query, errors.New("lat and lng, bbox or polygon is required")
+	}
+	return query, nil
+}
+
+// parsePoint parses a comma separated list of n numbers.
+func parsePoint(s string, n int) ([]float64, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != n {
+		return nil, errors.New("invalid number of coordinates")
+	}
+	point := make([]float64, n)
+	for i, part := range parts {
+		f, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return nil, err
+		}
+		point[i] = f
+	}
+	return point, nil
+}
+
+// validPoint reports whether the [longitude, latitude] point is within the coordinates range.
+func validPoint(point []float64) bool {
+	return point[0] >= -180 && point[0] <= 180 && point[1] >= -90 && point[1] <= 90
+}
+
+// readPlaceFilter reads the filter of the place listings from the query parameters ie.
+// 'category=coffee&city=Nairobi&min_rating=4&created_after=2022-01-01&owner=<user id>&sort=-created_at,title'.
+// Malformed values are returned as an error, the filter values themselves are checked by the validator.
+func readPlaceFilter(c *fiber.Ctx) (data.Filter, error) {
+	filter := data.Filter{
+		Categories: readList(c, "category"),
+		City:       c.Query("city"),
+		State:      c.Query("state"),
+		UserID:     c.Query("owner"),
+		Sort:       readList(c, "sort"),
+	}
+
+	if minRating := c.Query("min_rating"); minRating != "" {
+		var err error
+		if filter.MinRating, err = strconv.ParseFloat(minRating, 64); err != nil {
+			return filter, errors.New("min_rating must be a number")
+		}
+	}
+
+	var err error
+	if filter.CreatedAfter, err = readTime(c, "created_after"); err != nil {
+		return filter, err
+	}
+	if filter.CreatedBefore, err = readTime(c, "created_before"); err != nil {
+		return filter, err
+	}
+	return filter, nil
+}
+
+// readTime reads a time query parameter either in the RFC 3339 format or as a date ie. '2022-01-01', the zero time
+// is returned when the parameter is missing.
+func readTime(c *fiber.Ctx, key string) (time.Time, error) {
+	value := c.Query(key)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%s must be a date or an RFC 3339 time", key)
+}
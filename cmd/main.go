@@ -2,45 +2,114 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
+	"errors"
+	"flag"
 	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"sync/atomic"
+	"syscall"
 	"time"
 
+	firebase "firebase.google.com/go/v4"
+	"github.com/evansopilo/trouver/internal/authz"
 	"github.com/evansopilo/trouver/internal/data"
+	"github.com/evansopilo/trouver/internal/logging"
+	"github.com/evansopilo/trouver/internal/metrics"
+	"github.com/evansopilo/trouver/internal/tracing"
 	"github.com/sirupsen/logrus"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.opentelemetry.io/contrib/instrumentation/go.mongodb.org/mongo-driver/mongo/otelmongo"
 )
 
 // A config struct definition to hold all the configuration settings for our
-// application. For now, the only configuration settings will be the network
-// port that we want the server to listen on, and the name of the current
-// operating environment for the application (development, staging, production,
-// etc.). We will read in these
-// configuration settings from config file when the application starts.
+// application. The settings are read when the application starts from the
+// defaults, the optional YAML or TOML config file, the TROUVER_ prefixed
+// environment variables and the command-line flags, in the order of precedence.
+// See loadConfig for the names of the settings.
 type Config struct {
 	// Hold application version number.
-	Version string
+	Version string `yaml:"version" toml:"version"`
 	// Hold application name.
-	App string
-	// Hold configuration settings for the server, which will be read from the
-	// config file on application start-up.
+	App string `yaml:"app" toml:"app"`
+	// Hold configuration settings for the server, the Env is one of "development",
+	// "testing", "staging" or "production".
 	Server struct {
-		Port string
-		Env  string
-	}
-	// Hold the configuration settings for the database connection pool, which
-	// we will read in from config file.
+		Port string `yaml:"port" toml:"port"`
+		Env  string `yaml:"env" toml:"env"`
+		// ShutdownTimeout is the time allowed to drain the in-flight requests and close the database connections
+		// once a SIGINT or SIGTERM signal is received.
+		ShutdownTimeout time.Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout"`
+		// DrainDelay is the time the server keeps accepting requests while its readiness check reports it
+		// unavailable on shutdown, so that the load balancers stop routing requests to it before it stops listening.
+		DrainDelay time.Duration `yaml:"drain_delay" toml:"drain_delay"`
+	} `yaml:"server" toml:"server"`
+	// Hold the configuration settings for the database connection pool, and
+	// the names of the database and collections holding the documents.
 	DB struct {
-		DSN string
-	}
+		// Driver is either "mongo" (the default) or "memory" for the in-memory store used in the local development,
+		// the in-memory documents are lost on restart.
+		Driver      string `yaml:"driver" toml:"driver"`
+		DSN         string `yaml:"dsn" toml:"dsn"`
+		Database    string `yaml:"database" toml:"database"`
+		Collections struct {
+			Places  string `yaml:"places" toml:"places"`
+			Reviews string `yaml:"reviews" toml:"reviews"`
+			// Revisions holds the previous versions of the updated reviews.
+			Revisions string `yaml:"revisions" toml:"revisions"`
+		} `yaml:"collections" toml:"collections"`
+	} `yaml:"db" toml:"db"`
+	// Hold the configuration settings for the auth provider used to verify the id tokens. The provider is either
+	// "firebase" (the default) or "jwt" for locally issued tokens verified against the configured keys.
+	Auth struct {
+		Provider string          `yaml:"provider" toml:"provider"`
+		JWT      data.JWTOptions `yaml:"jwt" toml:"jwt"`
+	} `yaml:"auth" toml:"auth"`
+	// Hold the secret used to sign the pagination cursors, a random secret is generated on start-up when it is not
+	// set in which case the cursors are invalidated on restart.
+	Cursor struct {
+		Secret string `yaml:"secret" toml:"secret"`
+	} `yaml:"cursor" toml:"cursor"`
 	// Struct contains fields for the requests-per-second and burst values, and
 	// a boolean field which we can use to enable/disable rate limiting
 	// altogether.
 	Limiter struct {
-		RPS     float64
-		Burst   int
-		Enabled bool
-	}
+		RPS     float64 `yaml:"rps" toml:"rps"`
+		Burst   int     `yaml:"burst" toml:"burst"`
+		Enabled bool    `yaml:"enabled" toml:"enabled"`
+		// WriteRPS and WriteBurst hold the separate budget of the routes creating, updating and deleting records,
+		// the RPS and Burst budget applies to the read routes.
+		WriteRPS   float64 `yaml:"write_rps" toml:"write_rps"`
+		WriteBurst int     `yaml:"write_burst" toml:"write_burst"`
+		// AuthRPS and AuthBurst hold the budget per IP address of the authenticated routes, taken before the token
+		// is verified so that the requests with a missing or invalid token are limited too.
+		AuthRPS   float64 `yaml:"auth_rps" toml:"auth_rps"`
+		AuthBurst int     `yaml:"auth_burst" toml:"auth_burst"`
+	} `yaml:"limiter" toml:"limiter"`
+	// Hold the deadlines of the requests, the Routes override the Default deadline for the routes given by their
+	// method and path pattern, ie. "GET /v1/api/places/search".
+	Timeouts struct {
+		Default time.Duration            `yaml:"default" toml:"default"`
+		Routes  map[string]time.Duration `yaml:"routes" toml:"routes"`
+	} `yaml:"timeouts" toml:"timeouts"`
+	// Hold the settings of the trash, the deleted places and reviews are kept for the Retention before they are
+	// purged for good by the purge run every PurgeInterval.
+	Trash struct {
+		Retention     time.Duration `yaml:"retention" toml:"retention"`
+		PurgeInterval time.Duration `yaml:"purge_interval" toml:"purge_interval"`
+	} `yaml:"trash" toml:"trash"`
+	// Hold the configuration settings of the trace exporter, the Exporter is either "none", "stdout" or "otlp" to
+	// send the spans to the OpenTelemetry collector at the Endpoint.
+	Tracing struct {
+		Exporter    string  `yaml:"exporter" toml:"exporter"`
+		Endpoint    string  `yaml:"endpoint" toml:"endpoint"`
+		Insecure    bool    `yaml:"insecure" toml:"insecure"`
+		SampleRatio float64 `yaml:"sample_ratio" toml:"sample_ratio"`
+	} `yaml:"tracing" toml:"tracing"`
 }
 
 // An application struct to hold the dependencies for our HTTP handlers,
@@ -48,29 +117,193 @@ type Config struct {
 // config struct and a logger, but it will grow to include a lot more as our
 // build progresses.
 type Application struct {
-	Config Config
-	Logger logrus.Logger
-	Models data.Models
+	Config  Config
+	Logger  *logrus.Logger
+	Models  data.Models
+	Policy  authz.Policy
+	Metrics *metrics.Metrics
+
+	// client is the mongo client of the models, it is nil for the in-memory driver.
+	client *mongo.Client
+	// draining is set once the server starts shutting down, the readiness check then reports the server as
+	// unavailable so that the load balancers stop routing requests to it.
+	draining atomic.Bool
+	// requests is the parent context of the requests, cancelRequests cancels it once the shutdown timeout elapses
+	// so that the database operations of the requests still in flight are aborted.
+	requests       context.Context
+	cancelRequests context.CancelFunc
+	// flushTraces flushes the pending spans and stops the trace exporter.
+	flushTraces func(context.Context) error
+	// checks are the dependency checks run by the readiness check, they are registered when the dependencies are
+	// initialized.
+	checks []healthCheck
 }
 
 func main() {
-	var cfg Config
-	loadConfig(&cfg)
+	// the first argument is the command, the server is run when it is left out. The check command reports the
+	// orphaned and duplicated reviews and the repair command fixes them, the settings flags follow the command.
+	command, args := "serve", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+	if !oneOf(command, "serve", "check", "repair") {
+		logrus.Fatalf("unknown command %q, expected serve, check or repair", command)
+	}
+
+	cfg, err := loadConfig(args)
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err != nil {
+		logrus.Fatal(err)
+	}
+
+	logger := logging.New(cfg.Server.Env)
+
+	if cfg.Cursor.Secret == "" {
+		logger.Warn("cursor secret is not set, using a random secret")
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			logger.Fatal(err)
+		}
+		cfg.Cursor.Secret = string(secret)
+	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	client, err := mongo.Connect(ctx, options.Client().ApplyURI(cfg.DB.DSN))
+	app := &Application{
+		Config:  cfg,
+		Logger:  logger,
+		Policy:  authz.DefaultPolicy,
+		Metrics: metrics.New(cfg.App, cfg.Version),
+	}
+
+	// the tracer provider must be installed before the models are initialized, the mongo command monitor takes
+	// the global tracer provider when it is created.
+	app.flushTraces, err = tracing.Setup(ctx, tracing.Options{
+		Exporter:       cfg.Tracing.Exporter,
+		Endpoint:       cfg.Tracing.Endpoint,
+		Insecure:       cfg.Tracing.Insecure,
+		SampleRatio:    cfg.Tracing.SampleRatio,
+		ServiceName:    cfg.App,
+		ServiceVersion: cfg.Version,
+		Environment:    cfg.Server.Env,
+	})
 	if err != nil {
-		logrus.Fatal(err)
+		logger.Fatal(err)
 	}
 
-	app := &Application{
-		Config: cfg,
-		Models: data.Models{
-			Place: data.NewPlaceModel(client),
-		},
+	if err := app.initModels(ctx); err != nil {
+		logger.Fatal(err)
+	}
+
+	// the commands checking the consistency of the documents don't verify any token.
+	if command != "serve" {
+		if err := app.check(command == "repair"); err != nil {
+			logger.Error(err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// the indexes are not created by the check commands until the documents are repaired, the unique index of the
+	// reviews can't be created while a user has duplicated reviews. Building the indexes of a large collection takes
+	// longer than connecting to the database.
+	indexCtx, cancelIndex := context.WithTimeout(context.Background(), time.Minute)
+	defer cancelIndex()
+	if err := app.createIndexes(indexCtx); err != nil {
+		logger.WithError(err).Fatal("index creation failed, run the check command to find the documents in conflict")
+	}
+
+	if err := app.initAuth(ctx); err != nil {
+		logger.Fatal(err)
+	}
+
+	// the server is shut down gracefully on SIGINT and SIGTERM, the exit code is non-zero when the server fails
+	// or the shutdown is not clean.
+	quit, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	if err := app.serve(quit); err != nil {
+		logger.Error(err)
+		os.Exit(1)
+	}
+}
+
+// initModels initializes the place and review models of the configured database driver.
+func (app *Application) initModels(ctx context.Context) error {
+	switch app.Config.DB.Driver {
+	case "", "mongo":
+		// the monitor traces every command sent to mongo as a child span of the span carried by the context.
+		client, err := mongo.Connect(ctx, options.Client().ApplyURI(app.Config.DB.DSN).SetMonitor(otelmongo.NewMonitor()))
+		if err != nil {
+			return err
+		}
+		app.client = client
+		app.checks = append(app.checks, healthCheck{name: "database", critical: true, check: func(ctx context.Context) error {
+			return client.Ping(ctx, readpref.Primary())
+		}})
+		app.Models.Place = data.NewPlaceModel(client, app.Config.DB.Collections.Reviews)
+		app.Models.Review = data.NewReviewModel(client, app.Config.DB.Collections.Places, app.Config.DB.Collections.Revisions)
+	case "memory":
+		store := data.NewMemoryStore()
+		app.Models.Place = data.NewMemoryPlaceModel(store, app.Config.DB.Collections.Reviews)
+		app.Models.Review = data.NewMemoryReviewModel(store, app.Config.DB.Collections.Places, app.Config.DB.Collections.Revisions)
+		app.checks = append(app.checks, healthCheck{name: "database", critical: true, check: func(ctx context.Context) error {
+			return nil
+		}})
+	default:
+		return fmt.Errorf("unknown database driver %q", app.Config.DB.Driver)
+	}
+
+	// record the latency and the failures of the model methods, log them with the entry of the request and trace
+	// them as children of the span of the request.
+	app.Models = data.Observe(app.Models, app.Metrics)
+	app.Models = data.Observe(app.Models, logging.Observer{})
+	app.Models = data.Observe(app.Models, tracing.Observer{})
+	return nil
+}
+
+// createIndexes creates the indexes of the mongo collections, the indexes required by the place queries, so that the
+// search works on a fresh database, and the unique index of the reviews. The in-memory driver has no indexes.
+func (app *Application) createIndexes(ctx context.Context) error {
+	if app.client == nil {
+		return nil
+	}
+	placeModel := data.NewPlaceModel(app.client, app.Config.DB.Collections.Reviews)
+	if err := placeModel.CreateIndexes(ctx, app.Config.DB.Database, app.Config.DB.Collections.Places); err != nil {
+		return fmt.Errorf("create places indexes: %w", err)
+	}
+	reviewModel := data.NewReviewModel(app.client, app.Config.DB.Collections.Places, app.Config.DB.Collections.Revisions)
+	if err := reviewModel.CreateIndexes(ctx, app.Config.DB.Database, app.Config.DB.Collections.Reviews); err != nil {
+		return fmt.Errorf("create reviews indexes: %w", err)
+	}
+	return nil
+}
+
+// initAuth initializes the auth model of the configured auth provider.
+func (app *Application) initAuth(ctx context.Context) error {
+	switch app.Config.Auth.Provider {
+	case "", "firebase":
+		// initialize the firebase app used to verify id tokens, the credentials are read from the file pointed to
+		// by the GOOGLE_APPLICATION_CREDENTIALS environment variable.
+		firebaseApp, err := firebase.NewApp(ctx, nil)
+		if err != nil {
+			return err
+		}
+		app.Models.Auth = data.NewAuthModel(firebaseApp)
+	case "jwt":
+		jwtModel, err := data.NewJWTAuthModel(app.Config.Auth.JWT)
+		if err != nil {
+			return err
+		}
+		app.Models.Auth = jwtModel
+	default:
+		return fmt.Errorf("unknown auth provider %q", app.Config.Auth.Provider)
 	}
 
-	app.Router().Listen(fmt.Sprintf(":%v", app.Config.Server.Port))
+	// the reads don't need the auth provider, therefore a misconfigured provider only degrades the service.
+	app.checks = append(app.checks, healthCheck{name: "auth", critical: false, check: app.Models.Auth.Check})
+	return nil
 }
@@ -0,0 +1,16 @@
+package main
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp/fasthttpadaptor"
+)
+
+// ServeMetrics handler gets the application metrics on '/metrics' endpoint in
+// the Prometheus exposition format.
+func (app *Application) ServeMetrics() fiber.Handler {
+	handler := fasthttpadaptor.NewFastHTTPHandler(app.Metrics.Handler())
+	return func(c *fiber.Ctx) error {
+		handler(c.Context())
+		return nil
+	}
+}
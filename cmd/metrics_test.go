@@ -0,0 +1,48 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestServeMetrics(t *testing.T) {
+	ts := newTestServer(t)
+	ts.insertPlace(t, newPlace("place-1", "user-1", "Cafe"))
+
+	ts.do(t, http.MethodGet, "/v1/api/places", "", nil)
+	ts.do(t, http.MethodGet, "/v1/api/places/place-1", "", nil)
+
+	res, err := ts.router.Test(httptest.NewRequest(http.MethodGet, "/metrics", nil), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("got status %d; want %d", res.StatusCode, http.StatusOK)
+	}
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		`trouver_http_requests_total{method="GET",route="/v1/api/places",status="200"} 1`,
+		`trouver_http_requests_total{method="GET",route="/v1/api/places/:place_id",status="200"} 1`,
+		`trouver_http_request_duration_seconds_count{method="GET",route="/v1/api/places",status="200"} 1`,
+		`trouver_http_requests_in_flight 1`,
+		`trouver_db_operation_duration_seconds_count{method="List",model="PlaceModel"} 1`,
+		`trouver_db_operation_duration_seconds_count{method="InsertOne",model="PlaceModel"} 1`,
+		`trouver_build_info{app="trouver",goversion=`,
+		`go_goroutines`,
+	} {
+		if !strings.Contains(string(b), want) {
+			t.Errorf("metrics don't contain %q", want)
+		}
+	}
+	if strings.Contains(string(b), "trouver_db_operation_errors_total") {
+		t.Error("metrics contain database errors")
+	}
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/evansopilo/trouver/internal/authz"
+	"github.com/evansopilo/trouver/internal/logging"
+	"github.com/evansopilo/trouver/internal/ratelimit"
+	"github.com/evansopilo/trouver/internal/tracing"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Authenticate authenticates a request, middleware for verifying the bearer id token sent in the Authorization
+// header. On success the token subject and role are stored in the request locals as "user_id" and "user_role"
+// for the downstream handlers, otherwise a status unauthorized is returned back to the client.
+func (app *Application) Authenticate(c *fiber.Ctx) error {
+
+	// add the Vary: Authorization header to the response. This indicates to any caches that the response may vary
+	// based on the value of the Authorization header in the request.
+	c.Vary(fiber.HeaderAuthorization)
+
+	// the Authorization header is expected to be in the format "Bearer <token>", any other format is rejected.
+	headerParts := strings.Fields(c.Get(fiber.HeaderAuthorization))
+	if len(headerParts) != 2 || !strings.EqualFold(headerParts[0], "Bearer") {
+		return app.unauthorized(c, "missing or malformed authentication token")
+	}
+
+	// verifying the token may require fetching the public keys of the auth provider, therefore it is tied to the
+	// request context deadline.
+	ctx := c.UserContext()
+
+	token, err := app.Models.Auth.VerifyIDToken(ctx, headerParts[1])
+	if err != nil {
+		// invalid tokens are sent by the clients, they are not errors of the server and are only logged for
+		// debugging so that a flood of them doesn't flood the error logs too.
+		app.logger(c).WithError(err).Debug("invalid authentication token")
+		return app.unauthorized(c, "invalid or expired authentication token")
+	}
+
+	c.Locals("user_id", token.UID)
+	c.Locals("user_role", roleFromClaims(token.Claims))
+
+	// add the user id to the log entry of the request, so that the entries logged by the handlers and the models
+	// are attributed to the user.
+	c.SetUserContext(logging.WithEntry(c.UserContext(), logging.FromContext(c.UserContext()).WithField("user_id", token.UID)))
+
+	return c.Next()
+}
+
+// Authorize authorizes a request, middleware for checking that the role of the authenticated user is granted the
+// permission by the application policy, otherwise a status forbidden is returned back to the client. Permissions
+// only granted on owned resources are checked again by the handlers once the resource is read.
+func (app *Application) Authorize(permission authz.Permission) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		role, _ := c.Locals("user_role").(string)
+		if !app.Policy.Allows(role, permission) {
+			return app.forbidden(c, "permission denied")
+		}
+		return c.Next()
+	}
+}
+
+// can reports whether the authenticated user is granted the permission on a resource owned by the given user.
+func (app *Application) can(c *fiber.Ctx, permission authz.Permission, ownerID string) bool {
+	userID, _ := c.Locals("user_id").(string)
+	role, _ := c.Locals("user_role").(string)
+	return app.Policy.Can(authz.Subject{UserID: userID, Role: role}, permission, ownerID)
+}
+
+// forbidden returns the error of a status forbidden response with the given message, sent back to the client by
+// the error handler.
+func (app *Application) forbidden(c *fiber.Ctx, message string) error {
+	return fiber.NewError(fiber.StatusForbidden, message)
+}
+
+// RateLimit limits the rate of the requests per client, middleware for taking a token from the client bucket of
+// the limiter. Clients are identified by the authenticated user id, or by their IP address when the route is not
+// authenticated. The state of the bucket is sent in the RateLimit-* headers and a status too many requests is
+// returned back to the client with the Retry-After header once the bucket is empty.
+func (app *Application) RateLimit(limiter *ratelimit.Limiter) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		key := "ip:" + c.IP()
+		if userID, ok := c.Locals("user_id").(string); ok {
+			key = "user:" + userID
+		}
+
+		result := limiter.Allow(key)
+		c.Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+		c.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+
+		if !result.Allowed {
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(ceilSeconds(result.RetryAfter)))
+			return fiber.NewError(fiber.StatusTooManyRequests, "rate limit exceeded")
+		}
+		return c.Next()
+	}
+}
+
+// ceilSeconds rounds the duration up to whole seconds, as sent in the rate limit headers.
+func ceilSeconds(d time.Duration) int {
+	return int((d + time.Second - 1) / time.Second)
+}
+
+// Instrument records the metrics of the requests, middleware for tracking the in-flight requests and recording the
+// count and latency of the requests by method, route and status code once they are served.
+func (app *Application) Instrument(c *fiber.Ctx) error {
+	done := app.Metrics.TrackRequest()
+	err := c.Next()
+	done(c.Method(), c.Route().Path, statusOf(c, err))
+	return err
+}
+
+// RequestContext sets the parent context of the request, middleware for deriving the request context from the
+// context of the application requests, which is cancelled once the shutdown timeout elapses. fasthttp doesn't
+// report the client disconnects, the work of a request is bounded by its deadline instead.
+func (app *Application) RequestContext(c *fiber.Ctx) error {
+	c.SetUserContext(app.requests)
+	return c.Next()
+}
+
+// Timeout sets the deadline of the request, middleware for deriving the request context with the timeout so that
+// the database operations of the handlers are cancelled once it elapses. A status gateway timeout is returned back
+// to the client when the request failed after its deadline elapsed.
+func (app *Application) Timeout(timeout time.Duration) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx, cancel := context.WithTimeout(c.UserContext(), timeout)
+		defer cancel()
+		c.SetUserContext(ctx)
+
+		err := c.Next()
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) && (err != nil || c.Response().StatusCode() >= fiber.StatusInternalServerError) {
+			app.logger(c).WithField("timeout", timeout.String()).Warn("request deadline exceeded")
+			return fiber.NewError(fiber.StatusGatewayTimeout, "the request could not be completed in time")
+		}
+		return err
+	}
+}
+
+// RequestID identifies the requests, middleware for storing the request id in the request locals as "request_id"
+// and sending it back in the X-Request-ID header. The id sent by the client or a proxy in the X-Request-ID header
+// is honored so that the requests can be correlated across services, otherwise a new id is generated.
+func (app *Application) RequestID(c *fiber.Ctx) error {
+	requestID := c.Get(fiber.HeaderXRequestID)
+	if !validRequestID(requestID) {
+		requestID = uuid.New().String()
+	}
+	c.Locals("request_id", requestID)
+	c.Set(fiber.HeaderXRequestID, requestID)
+	return c.Next()
+}
+
+// LogRequest logs the requests, middleware for creating the log entry of the request carrying the request id, which
+// is passed through the request context to the handlers and the models, and logging the request once it is served
+// with its route, status code, user id and latency.
+func (app *Application) LogRequest(c *fiber.Ctx) error {
+	start := time.Now()
+	requestID, _ := c.Locals("request_id").(string)
+	c.SetUserContext(logging.WithEntry(c.UserContext(), app.Logger.WithField("request_id", requestID)))
+
+	err := c.Next()
+
+	status := statusOf(c, err)
+	entry := logging.FromContext(c.UserContext()).WithFields(logrus.Fields{
+		"method":  c.Method(),
+		"path":    c.Path(),
+		"route":   c.Route().Path,
+		"status":  status,
+		"latency": time.Since(start).String(),
+		"ip":      c.IP(),
+	})
+	if status >= fiber.StatusInternalServerError {
+		entry.Error("request failed")
+	} else {
+		entry.Info("request served")
+	}
+	return err
+}
+
+// Trace traces the requests, middleware for starting the server span of the request as a child of the trace
+// context sent by the caller in the W3C traceparent header. The span is carried by the request context to the
+// handlers and the models, and the trace id is added to the log entry of the request.
+func (app *Application) Trace(c *fiber.Ctx) error {
+	ctx := otel.GetTextMapPropagator().Extract(c.UserContext(), headerCarrier{c})
+	ctx, span := tracing.Tracer().Start(ctx, c.Method(),
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			semconv.HTTPMethodKey.String(c.Method()),
+			semconv.HTTPTargetKey.String(c.OriginalURL()),
+			semconv.HTTPUserAgentKey.String(c.Get(fiber.HeaderUserAgent)),
+			semconv.NetPeerIPKey.String(c.IP()),
+		),
+	)
+	defer span.End()
+
+	entry := logging.FromContext(ctx)
+	if span.SpanContext().HasTraceID() {
+		entry = entry.WithField("trace_id", span.SpanContext().TraceID().String())
+	}
+	c.SetUserContext(logging.WithEntry(ctx, entry))
+
+	err := c.Next()
+
+	// the route is only known once the request is routed to its handler.
+	status := statusOf(c, err)
+	span.SetName(c.Method() + " " + c.Route().Path)
+	span.SetAttributes(semconv.HTTPRouteKey.String(c.Route().Path), semconv.HTTPStatusCodeKey.Int(status))
+	if status >= fiber.StatusInternalServerError {
+		span.SetStatus(codes.Error, "")
+		if err != nil {
+			span.RecordError(err)
+		}
+	}
+	return err
+}
+
+// headerCarrier adapts the request and response headers to the carrier of the trace context propagator, the trace
+// context is extracted from the request headers and injected in the response headers.
+type headerCarrier struct{ c *fiber.Ctx }
+
+func (h headerCarrier) Get(key string) string { return h.c.Get(key) }
+
+func (h headerCarrier) Set(key, value string) { h.c.Set(key, value) }
+
+func (h headerCarrier) Keys() []string {
+	var keys []string
+	h.c.Request().Header.VisitAll(func(key, _ []byte) { keys = append(keys, string(key)) })
+	return keys
+}
+
+// logger returns the log entry of the request, carrying the request id, the user id and the route.
+func (app *Application) logger(c *fiber.Ctx) *logrus.Entry {
+	return logging.FromContext(c.UserContext()).WithField("route", c.Route().Path)
+}
+
+// statusOf returns the status code of the response to the request. The error returned by the handlers is only
+// turned into a response by the error handler once all the middlewares returned, therefore the status code is
+// taken from the error when there is one.
+func statusOf(c *fiber.Ctx, err error) int {
+	if err == nil {
+		return c.Response().StatusCode()
+	}
+	return errorStatus(err)
+}
+
+// validRequestID reports whether the request id sent by the client is safe to be logged and sent back, ids are
+// limited to 128 letters, digits and the characters "-", "_", "." and ":".
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, r := range id {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_.:", r)) {
+			return false
+		}
+	}
+	return true
+}
+
+// unauthorized returns the error of a status unauthorized response with the given message, sent back to the client
+// by the error handler.
+func (app *Application) unauthorized(c *fiber.Ctx, message string) error {
+	c.Set(fiber.HeaderWWWAuthenticate, "Bearer")
+	return fiber.NewError(fiber.StatusUnauthorized, message)
+}
+
+// roleFromClaims maps the custom claims of a verified token, as set by CustomClaimsSet, to the user role. A string
+// "role" claim takes precedence over the boolean "admin" claim, users without any role claim get the "user" role.
+func roleFromClaims(claims map[string]interface{}) string {
+	if role, ok := claims["role"].(string); ok && role != "" {
+		return role
+	}
+	if admin, ok := claims["admin"].(bool); ok && admin {
+		return authz.RoleAdmin
+	}
+	return authz.RoleUser
+}
@@ -0,0 +1,320 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestAuthenticate(t *testing.T) {
+	ts := newTestServer(t)
+	hook := test.NewLocal(ts.app.Logger)
+
+	tests := []struct {
+		name   string
+		header string
+		want   int
+	}{
+		{"missing token", "", http.StatusUnauthorized},
+		{"malformed header", "Token " + testToken(t, "user-1", ""), http.StatusUnauthorized},
+		{"invalid token", "Bearer abc", http.StatusUnauthorized},
+		{"expired token", "Bearer " + signToken(t, jwt.MapClaims{"sub": "user-1", "exp": time.Now().Add(-time.Hour).Unix()}), http.StatusUnauthorized},
+		{"token without expiry", "Bearer " + signToken(t, jwt.MapClaims{"sub": "user-1"}), http.StatusUnauthorized},
+		{"valid token", "Bearer " + testToken(t, "user-1", ""), http.StatusCreated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/v1/api/places", strings.NewReader(`{"title":"Cafe","description":"Coffee and cake."}`))
+			req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+			if tt.header != "" {
+				req.Header.Set(fiber.HeaderAuthorization, tt.header)
+			}
+			res, err := ts.router.Test(req, -1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.StatusCode != tt.want {
+				t.Errorf("got status %d; want %d", res.StatusCode, tt.want)
+			}
+		})
+	}
+
+	// the rejected tokens are sent by the clients, they are not logged as errors of the server.
+	for _, entry := range hook.AllEntries() {
+		if entry.Level <= logrus.WarnLevel {
+			t.Errorf("got %s entry %q", entry.Level, entry.Message)
+		}
+	}
+
+	// the subject of the token is stored for the handlers as the user id.
+	status, body := ts.do(t, http.MethodPost, "/v1/api/places", testToken(t, "user-1", ""), map[string]string{"title": "Cafe", "description": "Coffee and cake."})
+	if status != http.StatusCreated {
+		t.Fatalf("got status %d; want %d", status, http.StatusCreated)
+	}
+	_, body = ts.do(t, http.MethodGet, "/v1/api/places/"+dataOf(t, body)["id"].(string), "", nil)
+	if got := dataOf(t, body)["place"].(map[string]interface{})["user_id"]; got != "user-1" {
+		t.Errorf("got user_id %v; want %q", got, "user-1")
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	ts := newTestServer(t)
+	place := map[string]interface{}{"title": "Cafe", "description": "Coffee and cake."}
+
+	status, _ := ts.do(t, http.MethodPost, "/v1/api/places", testToken(t, "user-1", "guest"), place)
+	if status != http.StatusForbidden {
+		t.Errorf("unknown role: got status %d; want %d", status, http.StatusForbidden)
+	}
+	status, _ = ts.do(t, http.MethodPost, "/v1/api/places", testToken(t, "user-1", "business-owner"), place)
+	if status != http.StatusCreated {
+		t.Errorf("business owner: got status %d; want %d", status, http.StatusCreated)
+	}
+}
+
+func TestRoleFromClaims(t *testing.T) {
+	tests := []struct {
+		claims map[string]interface{}
+		want   string
+	}{
+		{map[string]interface{}{}, "user"},
+		{map[string]interface{}{"role": "moderator"}, "moderator"},
+		{map[string]interface{}{"admin": true}, "admin"},
+		{map[string]interface{}{"role": "moderator", "admin": true}, "moderator"},
+	}
+	for _, tt := range tests {
+		if got := roleFromClaims(tt.claims); got != tt.want {
+			t.Errorf("roleFromClaims(%v) = %q; want %q", tt.claims, got, tt.want)
+		}
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	ts := newTestServer(t)
+	ts.app.Config.Limiter.Enabled = true
+	ts.app.Config.Limiter.RPS = 1
+	ts.app.Config.Limiter.Burst = 2
+	ts.app.Config.Limiter.WriteRPS = 1
+	ts.app.Config.Limiter.WriteBurst = 1
+	ts.router = ts.app.Router()
+
+	get := func() *http.Response {
+		res, err := ts.router.Test(httptest.NewRequest(http.MethodGet, "/v1/api/places", nil), -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	for i, remaining := range []string{"1", "0"} {
+		res := get()
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("request %d: got status %d; want %d", i, res.StatusCode, http.StatusOK)
+		}
+		if got := res.Header.Get("RateLimit-Limit"); got != "2" {
+			t.Errorf("request %d: got RateLimit-Limit %q; want %q", i, got, "2")
+		}
+		if got := res.Header.Get("RateLimit-Remaining"); got != remaining {
+			t.Errorf("request %d: got RateLimit-Remaining %q; want %q", i, got, remaining)
+		}
+	}
+
+	res := get()
+	if res.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("got status %d; want %d", res.StatusCode, http.StatusTooManyRequests)
+	}
+	if got := res.Header.Get(fiber.HeaderRetryAfter); got != "1" {
+		t.Errorf("got Retry-After %q; want %q", got, "1")
+	}
+
+	// the write routes have their own budget per user, the read requests of the client don't count against it.
+	token := testToken(t, "user-1", "")
+	if status, _ := ts.do(t, http.MethodPost, "/v1/api/places", token, map[string]string{"title": "Cafe", "description": "Coffee and cake."}); status != http.StatusCreated {
+		t.Errorf("got status %d; want %d", status, http.StatusCreated)
+	}
+	if status, _ := ts.do(t, http.MethodPost, "/v1/api/places", token, map[string]string{"title": "Bar", "description": "Drinks."}); status != http.StatusTooManyRequests {
+		t.Errorf("got status %d; want %d", status, http.StatusTooManyRequests)
+	}
+	if status, _ := ts.do(t, http.MethodPost, "/v1/api/places", testToken(t, "user-2", ""), map[string]string{"title": "Bar", "description": "Drinks."}); status != http.StatusCreated {
+		t.Errorf("other user: got status %d; want %d", status, http.StatusCreated)
+	}
+}
+
+func TestRateLimitUnauthenticated(t *testing.T) {
+	ts := newTestServer(t)
+	ts.app.Config.Limiter.Enabled = true
+	ts.app.Config.Limiter.AuthRPS = 1
+	ts.app.Config.Limiter.AuthBurst = 2
+	ts.router = ts.app.Router()
+
+	// the requests with an invalid token are rejected by Authenticate, they are limited per IP address before.
+	for i, want := range []int{http.StatusUnauthorized, http.StatusUnauthorized, http.StatusTooManyRequests} {
+		if status, _ := ts.do(t, http.MethodPost, "/v1/api/places", "invalid", map[string]string{"title": "Cafe", "description": "Coffee and cake."}); status != want {
+			t.Errorf("request %d: got status %d; want %d", i, status, want)
+		}
+	}
+	if status, _ := ts.do(t, http.MethodDelete, "/v1/api/reviews/review-1", "", nil); status != http.StatusTooManyRequests {
+		t.Errorf("missing token: got status %d; want %d", status, http.StatusTooManyRequests)
+	}
+	if status, _ := ts.do(t, http.MethodPost, "/v1/api/places", testToken(t, "user-1", ""), map[string]string{"title": "Cafe", "description": "Coffee and cake."}); status != http.StatusTooManyRequests {
+		t.Errorf("valid token: got status %d; want %d", status, http.StatusTooManyRequests)
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	ts := newTestServer(t)
+
+	tests := []struct {
+		name   string
+		header string
+		keep   bool
+	}{
+		{"honored", "abc-123", true},
+		{"missing", "", false},
+		{"invalid", "abc 123\n", false},
+		{"too long", strings.Repeat("a", 129), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/v1/api/health", nil)
+			if tt.header != "" {
+				req.Header.Set(fiber.HeaderXRequestID, tt.header)
+			}
+			res, err := ts.router.Test(req, -1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := res.Header.Get(fiber.HeaderXRequestID)
+			if tt.keep && got != tt.header {
+				t.Errorf("got request id %q; want %q", got, tt.header)
+			}
+			if !tt.keep && (got == "" || got == tt.header) {
+				t.Errorf("got request id %q; want a generated id", got)
+			}
+		})
+	}
+}
+
+func TestLogRequest(t *testing.T) {
+	ts := newTestServer(t)
+	ts.app.Logger.SetLevel(logrus.DebugLevel)
+	hook := test.NewLocal(ts.app.Logger)
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/api/places", strings.NewReader(`{"title":"Cafe","description":"Coffee and cake."}`))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	req.Header.Set(fiber.HeaderAuthorization, "Bearer "+testToken(t, "user-1", ""))
+	req.Header.Set(fiber.HeaderXRequestID, "abc-123")
+	if _, err := ts.router.Test(req, -1); err != nil {
+		t.Fatal(err)
+	}
+
+	var served, operation *logrus.Entry
+	for _, entry := range hook.AllEntries() {
+		switch entry.Message {
+		case "request served":
+			served = entry
+		case "database operation":
+			operation = entry
+		}
+	}
+	if served == nil {
+		t.Fatal("request is not logged")
+	}
+	for key, want := range map[string]interface{}{
+		"request_id": "abc-123",
+		"user_id":    "user-1",
+		"route":      "/v1/api/places",
+		"method":     http.MethodPost,
+		"status":     http.StatusCreated,
+	} {
+		if served.Data[key] != want {
+			t.Errorf("got %s %v; want %v", key, served.Data[key], want)
+		}
+	}
+	if _, ok := served.Data["latency"]; !ok {
+		t.Error("request latency is not logged")
+	}
+
+	// the database operations are logged with the entry of the request.
+	if operation == nil {
+		t.Fatal("database operation is not logged")
+	}
+	if operation.Data["request_id"] != "abc-123" || operation.Data["model"] != "PlaceModel" || operation.Data["method"] != "InsertOne" {
+		t.Errorf("unexpected database operation entry %v", operation.Data)
+	}
+}
+
+func TestTrace(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(trace.NewNoopTracerProvider())
+		otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator())
+	})
+
+	ts := newTestServer(t)
+	ts.insertPlace(t, newPlace("place-1", "user-1", "Cafe"))
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest(http.MethodGet, "/v1/api/places/place-1", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	if _, err := ts.router.Test(req, -1); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+	server, ok := spans["GET /v1/api/places/:place_id"]
+	if !ok {
+		t.Fatalf("request span is not recorded: %v", spans)
+	}
+	if got := server.SpanContext().TraceID().String(); got != traceID {
+		t.Errorf("got trace id %s; want the incoming trace id %s", got, traceID)
+	}
+	if server.SpanKind() != trace.SpanKindServer {
+		t.Errorf("got span kind %v; want %v", server.SpanKind(), trace.SpanKindServer)
+	}
+
+	model, ok := spans["PlaceModel.FindOne"]
+	if !ok {
+		t.Fatalf("model span is not recorded: %v", spans)
+	}
+	if model.Parent().SpanID() != server.SpanContext().SpanID() {
+		t.Error("model span is not a child of the request span")
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	ts := newTestServer(t)
+	ts.app.Config.Timeouts.Routes = map[string]time.Duration{"GET /v1/api/places": time.Nanosecond}
+	ts.router = ts.app.Router()
+	ts.insertPlace(t, newPlace("place-1", "user-1", "Cafe"))
+
+	status, body := ts.do(t, http.MethodGet, "/v1/api/places", "", nil)
+	if status != http.StatusGatewayTimeout {
+		t.Fatalf("got status %d; want %d", status, http.StatusGatewayTimeout)
+	}
+	if body["status"] != float64(http.StatusGatewayTimeout) || body["title"] != "Gateway Timeout" {
+		t.Errorf("unexpected body %v", body)
+	}
+
+	// the other routes keep the default deadline.
+	if status, _ := ts.do(t, http.MethodGet, "/v1/api/places/place-1", "", nil); status != http.StatusOK {
+		t.Errorf("got status %d; want %d", status, http.StatusOK)
+	}
+}
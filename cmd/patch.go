@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"mime"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	"github.com/gofiber/fiber/v2"
+)
+
+// The media types of the PATCH request bodies, a plain JSON body is applied as a merge patch.
+const (
+	mimeMergePatch = "application/merge-patch+json"
+	mimeJSONPatch  = "application/json-patch+json"
+)
+
+// applyPatch applies the patch document of the request body to the JSON encoding of the document, the patched
+// document is decoded into out. The body is either a JSON Merge Patch (RFC 7386), where the fields set to null are
+// removed, or a JSON Patch (RFC 6902) given by the Content-Type header.
+func applyPatch(c *fiber.Ctx, doc, out interface{}) error {
+	original, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(c.Get(fiber.HeaderContentType))
+	var patched []byte
+	switch mediaType {
+	case mimeMergePatch, fiber.MIMEApplicationJSON:
+		patched, err = jsonpatch.MergePatch(original, c.Body())
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "malformed merge patch document")
+		}
+	case mimeJSONPatch:
+		patch, err := jsonpatch.DecodePatch(c.Body())
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "malformed json patch document")
+		}
+		patched, err = patch.Apply(original)
+		if err != nil {
+			return fiber.NewError(fiber.StatusUnprocessableEntity, "the patch could not be applied: "+err.Error())
+		}
+	default:
+		c.Set("Accept-Patch", mimeMergePatch+", "+mimeJSONPatch)
+		return fiber.NewError(fiber.StatusUnsupportedMediaType, "unsupported patch media type")
+	}
+
+	if err := json.Unmarshal(patched, out); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid request")
+	}
+	return nil
+}
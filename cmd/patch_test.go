@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestPatchPlace(t *testing.T) {
+	ts := newTestServer(t)
+	place := newPlace("place-1", "owner", "Java House")
+	place.Email = "hello@javahouse.co.ke"
+	place.Categories = []string{"coffee", "breakfast"}
+	ts.insertPlace(t, place)
+	token := testToken(t, "owner", "")
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        int
+	}{
+		{"merge patch", mimeMergePatch, `{"title": "Java House Westlands", "email": null, "user_id": "other"}`, http.StatusOK},
+		{"plain json", fiber.MIMEApplicationJSON, `{"phone_number": "+254700000000"}`, http.StatusOK},
+		{"json patch", mimeJSONPatch, `[{"op": "remove", "path": "/categories/1"}, {"op": "replace", "path": "/description", "value": "Coffee."}]`, http.StatusOK},
+		{"json patch test failed", mimeJSONPatch, `[{"op": "test", "path": "/title", "value": "Artcaffe"}]`, http.StatusUnprocessableEntity},
+		{"malformed json patch", mimeJSONPatch, `{"title": "Artcaffe"}`, http.StatusBadRequest},
+		{"cleared required field", mimeMergePatch, `{"title": null}`, http.StatusUnprocessableEntity},
+		{"unsupported media type", fiber.MIMETextPlain, `title=Artcaffe`, http.StatusUnsupportedMediaType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPatch, "/v1/api/places/place-1", strings.NewReader(tt.body))
+			req.Header.Set(fiber.HeaderContentType, tt.contentType)
+			req.Header.Set(fiber.HeaderAuthorization, "Bearer "+token)
+			res, err := ts.router.Test(req, -1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res.Body.Close()
+			if res.StatusCode != tt.want {
+				t.Fatalf("got status %d; want %d", res.StatusCode, tt.want)
+			}
+		})
+	}
+
+	got, err := ts.app.Models.Place.FindOne(context.Background(), ts.app.Config.DB.Database, ts.app.Config.DB.Collections.Places, "place-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Title != "Java House Westlands" || got.Email != "" || got.PhoneNumber != "+254700000000" || got.Description != "Coffee." {
+		t.Errorf("unexpected place %+v", got)
+	}
+	if len(got.Categories) != 1 || got.Categories[0] != "coffee" {
+		t.Errorf("got categories %v; want [coffee]", got.Categories)
+	}
+	if got.UserID != "owner" || got.CreatedAt.IsZero() {
+		t.Errorf("immutable fields changed: %+v", got)
+	}
+	if got.UpdatedAt.IsZero() || got.UpdatedAt.Before(got.CreatedAt) {
+		t.Errorf("got updated at %v; want it maintained", got.UpdatedAt)
+	}
+}
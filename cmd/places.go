@@ -1,54 +1,55 @@
 package main
 
 import (
-	"context"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/evansopilo/trouver/internal/authz"
 	"github.com/evansopilo/trouver/internal/data"
+	"github.com/evansopilo/trouver/internal/validator"
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
-	"github.com/sirupsen/logrus"
 )
 
 // CreatePlace creates a new place, handler for adding a new place to the application.
 func (app *Application) CreatePlace(c *fiber.Ctx) error {
 
-	// create a context with a 5-second timeout deadline. The entire request response cycle will be
-	// tied to this context, therefore response should be returned within the defined context timeout.
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+	// the request context carries the deadline of the route, the database operations are cancelled once it
+	// elapses or the server is shut down.
+	ctx := c.UserContext()
 
 	var place data.Place
 
 	// decode the request body to place variable declared and continue with the request flow
 	// when the decode is successfull otherwise return a status bad request back to the client.
 	if err := c.BodyParser(&place); err != nil {
-		logrus.Println(err)
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"status":  "error",
-			"message": "invalid request",
-		})
+		app.logger(c).Error(err)
+		return fiber.NewError(fiber.StatusBadRequest, "invalid request")
 	}
 
 	// add place user id to user id obtained from auth token claims.
 	place.UserID = c.Locals("user_id").(string)
 
+	// the rating statistics are maintained by the reviews and the tombstone by the trash, clear any sent by the client.
+	place.RatingStats = data.RatingStats{}
+	place.Tombstone = data.Tombstone{}
+
+	// validate the place and report any validation error with a status unprocessable entity.
+	if err := validator.ValidatePlace(&place); err != nil {
+		return err
+	}
+
 	// add timestamp of current time to the create time of place object.
 	place.CreatedAt = time.Now()
 
 	// add place id from a random generated uuid.
 	place.ID = uuid.New().String()
 
-	// insert the new place record to the database within the defined context with timeout. When the
-	// operation fails for any reason ie. elapsed context timeout reponse with a 500 Internal Server error
-	// is returned.
-	if err := app.Models.Place.InsertOne(ctx, "trouver", "places", &place); err != nil {
-		logrus.Println(err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"status":  "error",
-			"message": "create place failed",
-		})
+	// insert the new place record to the database within the defined context with timeout. The error of the
+	// operation is sent back by the error handler ie. a status conflict for a duplicate document.
+	if err := app.Models.Place.InsertOne(ctx, app.Config.DB.Database, app.Config.DB.Collections.Places, &place); err != nil {
+		return err
 	}
 
 	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
@@ -63,21 +64,21 @@ func (app *Application) CreatePlace(c *fiber.Ctx) error {
 // GetPlace gets place, handler for getting a place from the application by given id.
 func (app *Application) GetPlace(c *fiber.Ctx) error {
 
-	// create a context with a 5-second timeout deadline. The entire request response cycle will be
-	// tied to this context, therefore response should be returned within the defined context timeout.
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+	// the request context carries the deadline of the route, the database operations are cancelled once it
+	// elapses or the server is shut down.
+	ctx := c.UserContext()
 
-	// get place record with the provided id from the database within the defined context with timeout.When the
-	// operation fails for any reason ie. elapsed context timeout reponse with a 500 Internal Server error
-	// is returned.
-	place, err := app.Models.Place.FindOne(ctx, "trouver", "places", c.Params("place_id"))
+	// get place record with the provided id from the database within the defined context with timeout. The error of the
+	// operation is sent back by the error handler ie. a status not found when the document doesn't exist.
+	place, err := app.Models.Place.FindOne(ctx, app.Config.DB.Database, app.Config.DB.Collections.Places, c.Params("place_id"))
 	if err != nil {
-		logrus.Println(err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"status":  "error",
-			"message": "read place failed",
-		})
+		return err
+	}
+
+	// the entity tag of the place is its version, a status not modified is returned back to the client when it
+	// already has this version of the place.
+	if notModified(c, place.Version) {
+		return nil
 	}
 
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
@@ -92,24 +93,85 @@ func (app *Application) GetPlace(c *fiber.Ctx) error {
 // ListPlace lists places, handler from updating a place in the application.
 func (app *Application) ListPlace(c *fiber.Ctx) error {
 
-	// create a context with a 5-second timeout deadline. The entire request response cycle will be
-	// tied to this context, therefore response should be returned within the defined context timeout.
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+	// the request context carries the deadline of the route, the database operations are cancelled once it
+	// elapses or the server is shut down.
+	ctx := c.UserContext()
+
+	// read the filters and sort fields from the query string, malformed values are reported with a status bad request
+	// and values failing the validation ie. unknown sort fields with a status unprocessable entity.
+	filter, err := readPlaceFilter(c)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	page_size, err := app.readPage(c, &filter)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	if err := validator.ValidateFilter(&filter, data.PlaceSortSafelist); err != nil {
+		return err
+	}
+
+	// get place records with the provided filters from the database within the defined context with timeout. The error of the
+	// operation is sent back by the error handler ie. a status service unavailable when the database can't be reached.
+	places, err := app.Models.Place.List(ctx, app.Config.DB.Database, app.Config.DB.Collections.Places, filter)
+	if err != nil {
+		return err
+	}
+
+	// the extra place over the page size tells that there are more pages, the next cursor is only given in the
+	// default order of the places.
+	metadata := data.Metadata{PageSize: page_size}
+	if len(*places) > page_size {
+		*places = (*places)[:page_size]
+		metadata.HasMore = true
+		if len(filter.Sort) == 0 {
+			last := (*places)[page_size-1]
+			metadata.NextCursor = app.nextCursor(last.CreatedAt, last.ID)
+		}
+	}
+
+	// the total is only counted for the page numbered listings, clients paging with the cursor don't need it.
+	if filter.After == nil {
+		total, err := app.Models.Place.Count(ctx, app.Config.DB.Database, app.Config.DB.Collections.Places, filter)
+		if err != nil {
+			return err
+		}
+		metadata.Total = &total
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":   "success",
+		"data":     places,
+		"metadata": metadata,
+	})
+}
+
+// SearchPlace searches places, handler for full text searching places in the application by the 'q' query term.
+// The results are sorted by relevance and each place carries its relevance score.
+func (app *Application) SearchPlace(c *fiber.Ctx) error {
+
+	// the request context carries the deadline of the route, the database operations are cancelled once it
+	// elapses or the server is shut down.
+	ctx := c.UserContext()
+
+	term := strings.TrimSpace(c.Query("q"))
+	if term == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "missing search term")
+	}
+
 	page, _ := strconv.Atoi(c.Query("page", "1"))
 	page_size, _ := strconv.Atoi(c.Query("size", "10"))
-	skip := (page-1)*page_size + 1
+	if page < 1 || page_size < 1 || page_size > 100 {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid page or size")
+	}
 
-	// get place records with the provided filters from the database within the defined context with timeout.When the
-	// operation fails for any reason ie. elapsed context timeout reponse with a 500 Internal Server error
-	// is returned.
-	places, err := app.Models.Place.List(ctx, "trouver", "places", data.Filter{Skip: skip, Limit: page_size})
+	filter := data.Filter{Skip: (page - 1) * page_size, Limit: page_size, Categories: readList(c, "category")}
+
+	// search place records matching the term from the database within the defined context with timeout. The error of the
+	// operation is sent back by the error handler ie. a status service unavailable when the database can't be reached.
+	places, err := app.Models.Place.SearchPlace(ctx, app.Config.DB.Database, app.Config.DB.Collections.Places, term, filter)
 	if err != nil {
-		logrus.Println(err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"status":  "error",
-			"message": "read place failed",
-		})
+		return err
 	}
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"status": "success",
@@ -117,60 +179,92 @@ func (app *Application) ListPlace(c *fiber.Ctx) error {
 	})
 }
 
-// UpdatePlace updates place, handler from updating a place in the application.
-func (app *Application) UpdateOne(c *fiber.Ctx) error {
+// NearbyPlace lists nearby places, handler for finding places in the application by location. Places are either
+// near the 'lat' and 'lng' point within the 'radius' in meters, sorted from the nearest with their distance, or
+// within the 'bbox' bounding box or 'polygon' of a map viewport.
+func (app *Application) NearbyPlace(c *fiber.Ctx) error {
 
-	// create a context with a 5-second timeout deadline. The entire request response cycle will be
-	// tied to this context, therefore response should be returned within the defined context timeout.
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+	// the request context carries the deadline of the route, the database operations are cancelled once it
+	// elapses or the server is shut down.
+	ctx := c.UserContext()
 
-	var place data.Place
+	query, err := readGeoQuery(c)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
 
-	// add place id to id obtained from prams
-	place.ID = c.Params("place_id")
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	page_size, _ := strconv.Atoi(c.Query("size", "10"))
+	if page < 1 || page_size < 1 || page_size > 100 {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid page or size")
+	}
 
-	// add user id to user id obtained from auth token claims.
-	place.UserID = c.Locals("user_id").(string)
+	filter := data.Filter{Skip: (page - 1) * page_size, Limit: page_size, Categories: readList(c, "category")}
 
-	// decode the request body to place variable declared and continue with the request flow
-	// when the decode is successfull otherwise return a status bad request back to the client.
-	if err := c.BodyParser(&place); err != nil {
-		logrus.Println(err)
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"status":  "error",
-			"message": "invalid user request",
-		})
+	// get place records matching the geo query from the database within the defined context with timeout. The error of the
+	// operation is sent back by the error handler ie. a status service unavailable when the database can't be reached.
+	places, err := app.Models.Place.Nearby(ctx, app.Config.DB.Database, app.Config.DB.Collections.Places, query, filter)
+	if err != nil {
+		return err
 	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status": "success",
+		"data":   places,
+	})
+}
 
-	existingPlace, err := app.Models.Place.FindOne(ctx, "trouver", "places", c.Params("place_id"))
+// UpdatePlace updates place, handler from updating a place in the application.
+func (app *Application) UpdateOne(c *fiber.Ctx) error {
+
+	// the request context carries the deadline of the route, the database operations are cancelled once it
+	// elapses or the server is shut down.
+	ctx := c.UserContext()
+
+	existingPlace, err := app.Models.Place.FindOne(ctx, app.Config.DB.Database, app.Config.DB.Collections.Places, c.Params("place_id"))
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"status":  "error",
-			"message": "update place failed",
-		})
+		return err
 	}
 
-	// for successfull update, the place to be updated must be specific to the user or the user must be an admin.
+	// for successfull update, the user must be granted the place:update permission on the place by the application policy,
 	// otherwise returns a status forbidden(user has no permission to update the record).
-	if !(existingPlace.UserID == c.Locals("user_id").(string) || c.Locals("user_role").(string) == "admin") {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
-			"status":  "error",
-			"message": "update place failed",
-		})
+	if !app.can(c, authz.PlaceUpdate, existingPlace.UserID) {
+		return app.forbidden(c, "permission denied")
+	}
+
+	// the update is made from the version of the place given by the If-Match header, otherwise a status precondition
+	// failed is returned so that the changes of another client are not overwritten.
+	if err := checkIfMatch(c, existingPlace.Version); err != nil {
+		return err
+	}
+
+	// apply the merge patch or json patch of the request body to the existing place, only the fields given by the
+	// patch are changed and the fields set to null are removed. The id, owner and create time of a place can't be
+	// changed and the rating statistics and version are maintained by the models, any change of them by the patch is
+	// discarded.
+	var place data.Place
+	if err := applyPatch(c, existingPlace, &place); err != nil {
+		return err
 	}
+	place.ID, place.UserID, place.CreatedAt = existingPlace.ID, existingPlace.UserID, existingPlace.CreatedAt
+	place.RatingStats, place.Version = existingPlace.RatingStats, existingPlace.Version
 
-	// update the place record to the database within the defined context with timeout. When the
-	// operation fails for any reason ie. elapsed context timeout reponse with a 500 Internal Server error
-	// is returned.
-	if err := app.Models.Place.UpdateOne(ctx, "trouver", "places", &place); err != nil {
-		logrus.Println(err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"status":  "error",
-			"message": "update place failed",
-		})
+	// validate the place as it will be after the update, any validation error is reported with a status
+	// unprocessable entity.
+	if err := validator.ValidatePlace(&place); err != nil {
+		return err
 	}
 
+	// add timestamp of current time to the update time of place object.
+	place.UpdatedAt = time.Now()
+
+	// update the place record to the database within the defined context with timeout. The error of the
+	// operation is sent back by the error handler ie. a status not found when the document doesn't exist, or a
+	// status conflict when the place was written by another request since it was read.
+	if err := app.Models.Place.UpdateOne(ctx, app.Config.DB.Database, app.Config.DB.Collections.Places, &place); err != nil {
+		return ifMatchConflict(c, err)
+	}
+
+	c.Set(fiber.HeaderETag, entityTag(place.Version))
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"status":  "success",
 		"message": "update place success",
@@ -183,39 +277,34 @@ func (app *Application) UpdateOne(c *fiber.Ctx) error {
 // DeletePlace delete place, handler from deleting a place in the application.
 func (app *Application) DeletePlace(c *fiber.Ctx) error {
 
-	// create a context with a 5-second timeout deadline. The entire request response cycle will be
-	// tied to this context, therefore response should be returned within the defined context timeout.
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+	// the request context carries the deadline of the route, the database operations are cancelled once it
+	// elapses or the server is shut down.
+	ctx := c.UserContext()
 
 	// get the place with the provided id from the database.
-	existingPlace, err := app.Models.Place.FindOne(ctx, "trouver", "places", c.Params("place_id"))
+	existingPlace, err := app.Models.Place.FindOne(ctx, app.Config.DB.Database, app.Config.DB.Collections.Places, c.Params("place_id"))
 	if err != nil {
-		logrus.Println(err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"status":  "error",
-			"message": "delete place failed",
-		})
+		return err
 	}
 
-	// for successfull delete, the place to be delete must be specific to the user or the user must be an admin.
+	// for successfull delete, the user must be granted the place:delete permission on the place by the application policy,
 	// otherwise returns a status forbidden(user has no permission to delete the record).
-	if !(existingPlace.UserID == c.Locals("user_id").(string) || c.Locals("user_role") == "admin") {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
-			"status":  "error",
-			"message": "update place failed",
-		})
-	}
-
-	// delete the place record to the database within the defined context with timeout. When the
-	// operation fails for any reason ie. elapsed context timeout reponse with a 500 Internal Server error
-	// is returned.
-	if err := app.Models.Place.DeleteOne(ctx, "trouver", "places", c.Params("place_id")); err != nil {
-		logrus.Println(err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"status":  "error",
-			"message": "delete place failed",
-		})
+	if !app.can(c, authz.PlaceDelete, existingPlace.UserID) {
+		return app.forbidden(c, "permission denied")
+	}
+
+	// the delete is made from the version of the place given by the If-Match header, otherwise a status precondition
+	// failed is returned.
+	if err := checkIfMatch(c, existingPlace.Version); err != nil {
+		return err
+	}
+
+	// move the place record to the trash within the defined context with timeout, it can be restored by an admin until
+	// it is purged once the trash retention elapsed. The error of the operation is sent back by the error handler ie. a
+	// status not found when the document doesn't exist, or a status conflict when the place was written by another
+	// request since it was read.
+	if err := app.Models.Place.DeleteOne(ctx, app.Config.DB.Database, app.Config.DB.Collections.Places, existingPlace.ID, existingPlace.Version, c.Locals("user_id").(string)); err != nil {
+		return ifMatchConflict(c, err)
 	}
 
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
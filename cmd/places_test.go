@@ -0,0 +1,356 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/evansopilo/trouver/internal/data"
+)
+
+func TestCreatePlace(t *testing.T) {
+	ts := newTestServer(t)
+	token := testToken(t, "user-1", "")
+
+	status, body := ts.do(t, http.MethodPost, "/v1/api/places", token, map[string]interface{}{
+		"title":       "Java House",
+		"description": "Coffee and breakfast.",
+		"categories":  []string{"coffee"},
+		"location": map[string]interface{}{
+			"geo": map[string]interface{}{"type": "Point", "coordinates": []float64{36.8219, -1.2921}},
+		},
+		"average_rating": 5,
+	})
+	if status != http.StatusCreated {
+		t.Fatalf("got status %d; want %d: %v", status, http.StatusCreated, body)
+	}
+
+	place, err := ts.app.Models.Place.FindOne(context.Background(), ts.app.Config.DB.Database, ts.app.Config.DB.Collections.Places, dataOf(t, body)["id"].(string))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if place.UserID != "user-1" || place.Title != "Java House" || place.CreatedAt.IsZero() {
+		t.Errorf("unexpected place %+v", place)
+	}
+	if place.AverageRating != 0 {
+		t.Errorf("got average rating %v; want the client rating to be ignored", place.AverageRating)
+	}
+
+	t.Run("invalid place", func(t *testing.T) {
+		status, body := ts.do(t, http.MethodPost, "/v1/api/places", token, map[string]interface{}{
+			"description": "No title.",
+			"location": map[string]interface{}{
+				"geo": map[string]interface{}{"type": "Point", "coordinates": []float64{200, 0}},
+			},
+		})
+		if status != http.StatusUnprocessableEntity {
+			t.Fatalf("got status %d; want %d", status, http.StatusUnprocessableEntity)
+		}
+		errs, _ := body["errors"].(map[string]interface{})
+		for _, field := range []string{"title", "location.geo.coordinates"} {
+			if _, ok := errs[field]; !ok {
+				t.Errorf("missing error for field %q in %v", field, errs)
+			}
+		}
+	})
+}
+
+func TestGetPlace(t *testing.T) {
+	ts := newTestServer(t)
+	ts.insertPlace(t, newPlace("place-1", "user-1", "Java House"))
+
+	status, body := ts.do(t, http.MethodGet, "/v1/api/places/place-1", "", nil)
+	if status != http.StatusOK {
+		t.Fatalf("got status %d; want %d", status, http.StatusOK)
+	}
+	place := dataOf(t, body)["place"].(map[string]interface{})
+	if place["id"] != "place-1" || place["title"] != "Java House" {
+		t.Errorf("unexpected place %v", place)
+	}
+	if _, ok := place["review_count"]; !ok {
+		t.Errorf("place has no rating statistics: %v", place)
+	}
+}
+
+func TestListPlace(t *testing.T) {
+	ts := newTestServer(t)
+	now := time.Now()
+	for i, p := range []struct {
+		id, owner, title, city string
+		categories             []string
+		rating                 float64
+	}{
+		{"place-1", "user-1", "Bakery", "Nairobi", []string{"bakery"}, 4.5},
+		{"place-2", "user-1", "Cafe", "Nairobi", []string{"coffee"}, 3},
+		{"place-3", "user-2", "Archive", "Mombasa", []string{"museum"}, 4},
+		{"place-4", "user-1", "Diner", "Nairobi", []string{"food", "coffee"}, 0},
+	} {
+		place := newPlace(p.id, p.owner, p.title)
+		place.Categories = p.categories
+		place.Location.Address.City = p.city
+		place.AverageRating = p.rating
+		place.CreatedAt = now.Add(time.Duration(i) * time.Minute)
+		ts.insertPlace(t, place)
+	}
+	between := func(from, to int) string {
+		return fmt.Sprintf("created_after=%s&created_before=%s",
+			url.QueryEscape(now.Add(time.Duration(from)*time.Minute-time.Second).Format(time.RFC3339)),
+			url.QueryEscape(now.Add(time.Duration(to)*time.Minute+time.Second).Format(time.RFC3339)))
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{"newest first", "", []string{"place-4", "place-3", "place-2", "place-1"}},
+		{"category", "category=coffee", []string{"place-4", "place-2"}},
+		{"city", "city=Nairobi&sort=title", []string{"place-1", "place-2", "place-4"}},
+		{"sort descending", "sort=-title", []string{"place-4", "place-2", "place-1", "place-3"}},
+		{"page", "size=2&page=2", []string{"place-2", "place-1"}},
+		{"categories", "category=museum,bakery", []string{"place-3", "place-1"}},
+		{"city and category", "city=Nairobi&category=coffee", []string{"place-4", "place-2"}},
+		{"unknown city", "city=Kisumu", []string{}},
+		{"owner", "owner=user-2", []string{"place-3"}},
+		{"min rating", "min_rating=4&sort=-average_rating", []string{"place-1", "place-3"}},
+		{"created range", between(1, 2), []string{"place-3", "place-2"}},
+		{"sort fields", "sort=-average_rating,title", []string{"place-1", "place-3", "place-2", "place-4"}},
+		{"sort ascending", "sort=created_at", []string{"place-1", "place-2", "place-3", "place-4"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := ts.do(t, http.MethodGet, "/v1/api/places?"+tt.query, "", nil)
+			if status != http.StatusOK {
+				t.Fatalf("got status %d; want %d: %v", status, http.StatusOK, body)
+			}
+			if got := idsOf(t, body); !equalStrings(got, tt.want) {
+				t.Errorf("got places %v; want %v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("cursor", func(t *testing.T) {
+		var got []string
+		query := "size=3"
+		for {
+			status, body := ts.do(t, http.MethodGet, "/v1/api/places?"+query, "", nil)
+			if status != http.StatusOK {
+				t.Fatalf("got status %d; want %d: %v", status, http.StatusOK, body)
+			}
+			got = append(got, idsOf(t, body)...)
+			metadata := body["metadata"].(map[string]interface{})
+			if metadata["has_more"] != true {
+				break
+			}
+			query = "size=3&cursor=" + url.QueryEscape(metadata["next_cursor"].(string))
+		}
+		if want := []string{"place-4", "place-3", "place-2", "place-1"}; !equalStrings(got, want) {
+			t.Errorf("got places %v; want %v", got, want)
+		}
+	})
+
+	t.Run("total", func(t *testing.T) {
+		_, body := ts.do(t, http.MethodGet, "/v1/api/places?size=1&city=Nairobi", "", nil)
+		metadata := body["metadata"].(map[string]interface{})
+		if metadata["total"] != float64(3) || metadata["has_more"] != true {
+			t.Errorf("unexpected metadata %v", metadata)
+		}
+	})
+
+	t.Run("invalid query", func(t *testing.T) {
+		for query, want := range map[string]int{
+			"sort=unknown":               http.StatusUnprocessableEntity,
+			"min_rating=9":               http.StatusUnprocessableEntity,
+			"sort=title&cursor=" + "a.b": http.StatusBadRequest,
+			"created_after=yesterday":    http.StatusBadRequest,
+			"size=1000":                  http.StatusBadRequest,
+			"min_rating=high":            http.StatusBadRequest,
+			"sort=-owner":                http.StatusUnprocessableEntity,
+			"sort=title,,-":              http.StatusUnprocessableEntity,
+			"created_after=2022-02-01&created_before=2022-01-01": http.StatusUnprocessableEntity,
+		} {
+			if status, _ := ts.do(t, http.MethodGet, "/v1/api/places?"+query, "", nil); status != want {
+				t.Errorf("%s: got status %d; want %d", query, status, want)
+			}
+		}
+	})
+}
+
+func TestSearchPlace(t *testing.T) {
+	ts := newTestServer(t)
+	coffee := newPlace("place-1", "user-1", "Coffee")
+	coffee.Categories = []string{"cafe"}
+	ts.insertPlace(t, coffee)
+	house := newPlace("place-2", "user-1", "Coffee House")
+	house.Categories = []string{"restaurant"}
+	ts.insertPlace(t, house)
+	tea := newPlace("place-3", "user-1", "Tea Room")
+	tea.Description = "Tea and coffee."
+	tea.Categories = []string{"cafe"}
+	ts.insertPlace(t, tea)
+	ts.insertPlace(t, newPlace("place-4", "user-1", "Bookshop"))
+
+	status, body := ts.do(t, http.MethodGet, "/v1/api/places/search?q=coffee", "", nil)
+	if status != http.StatusOK {
+		t.Fatalf("got status %d; want %d", status, http.StatusOK)
+	}
+	if got, want := idsOf(t, body), []string{"place-1", "place-2", "place-3"}; !equalStrings(got, want) {
+		t.Errorf("got places %v; want %v", got, want)
+	}
+	for _, item := range body["data"].([]interface{}) {
+		if score, _ := item.(map[string]interface{})["score"].(float64); score <= 0 {
+			t.Errorf("place has no relevance score: %v", item)
+		}
+	}
+
+	_, body = ts.do(t, http.MethodGet, "/v1/api/places/search?q=coffee&category=cafe", "", nil)
+	if got, want := idsOf(t, body), []string{"place-1", "place-3"}; !equalStrings(got, want) {
+		t.Errorf("category: got places %v; want %v", got, want)
+	}
+
+	// the places are paged in the order of their relevance, the terms match regardless of their case.
+	_, body = ts.do(t, http.MethodGet, "/v1/api/places/search?q=COFFEE&page=2&size=1", "", nil)
+	if got, want := idsOf(t, body), []string{"place-2"}; !equalStrings(got, want) {
+		t.Errorf("page: got places %v; want %v", got, want)
+	}
+	_, body = ts.do(t, http.MethodGet, "/v1/api/places/search?q=coffee&page=4&size=1", "", nil)
+	if got := idsOf(t, body); len(got) != 0 {
+		t.Errorf("last page: got places %v; want none", got)
+	}
+
+	// the places in the trash are not found.
+	if status, _ := ts.do(t, http.MethodDelete, "/v1/api/places/place-1", testToken(t, "user-1", ""), nil); status != http.StatusOK {
+		t.Fatalf("delete: got status %d; want %d", status, http.StatusOK)
+	}
+	_, body = ts.do(t, http.MethodGet, "/v1/api/places/search?q=coffee", "", nil)
+	if got, want := idsOf(t, body), []string{"place-2", "place-3"}; !equalStrings(got, want) {
+		t.Errorf("trash: got places %v; want %v", got, want)
+	}
+
+	for _, query := range []string{"q=", "q=%20%20", "q=coffee&page=0", "q=coffee&size=0", "q=coffee&size=101", "q=coffee&page=first"} {
+		if status, _ := ts.do(t, http.MethodGet, "/v1/api/places/search?"+query, "", nil); status != http.StatusBadRequest {
+			t.Errorf("%s: got status %d; want %d", query, status, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestNearbyPlace(t *testing.T) {
+	ts := newTestServer(t)
+	created := time.Now()
+	for i, p := range []struct {
+		id       string
+		lng, lat float64
+	}{
+		{"cbd", 36.8219, -1.2921},
+		{"westlands", 36.8065, -1.2676},
+		{"mombasa", 39.6682, -4.0435},
+	} {
+		place := newPlace(p.id, "user-1", p.id)
+		place.Location.Geo = data.Geo{Type: "Point", Coordinates: []float64{p.lng, p.lat}}
+		place.CreatedAt = created.Add(time.Duration(i) * time.Minute)
+		ts.insertPlace(t, place)
+	}
+	ts.insertPlace(t, newPlace("nowhere", "user-1", "nowhere"))
+
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{"nearest first", "lat=-1.2676&lng=36.8065", []string{"westlands", "cbd", "mombasa"}},
+		{"radius", "lat=-1.2921&lng=36.8219&radius=10000", []string{"cbd", "westlands"}},
+		{"bbox", "bbox=36.7,-1.3,36.9,-1.2", []string{"westlands", "cbd"}},
+		{"polygon", "polygon=39,-5;40,-5;40,-3;39,-3", []string{"mombasa"}},
+		// the places within a box or polygon are listed from the newest.
+		{"bbox newest first", "bbox=36,-5,40,-1", []string{"mombasa", "westlands", "cbd"}},
+		{"bbox second page", "bbox=36,-5,40,-1&page=2&size=2", []string{"cbd"}},
+		{"closed polygon", "polygon=36.7,-1.3;36.9,-1.3;36.9,-1.2;36.7,-1.2;36.7,-1.3", []string{"westlands", "cbd"}},
+		{"radius and bbox", "lat=-1.2921&lng=36.8219&radius=1000000&bbox=39,-5,40,-3", []string{"mombasa"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := ts.do(t, http.MethodGet, "/v1/api/places/nearby?"+tt.query, "", nil)
+			if status != http.StatusOK {
+				t.Fatalf("got status %d; want %d: %v", status, http.StatusOK, body)
+			}
+			if got := idsOf(t, body); !equalStrings(got, tt.want) {
+				t.Errorf("got places %v; want %v", got, tt.want)
+			}
+		})
+	}
+
+	_, body := ts.do(t, http.MethodGet, "/v1/api/places/nearby?lat=-1.2921&lng=36.8219&radius=10000", "", nil)
+	westlands := body["data"].([]interface{})[1].(map[string]interface{})
+	if distance := westlands["distance"].(float64); distance < 3000 || distance > 3300 {
+		t.Errorf("got distance %v; want about 3.2km", distance)
+	}
+
+	for _, query := range []string{"", "lat=100&lng=0", "lat=0", "bbox=1,2,3", "polygon=1,1;2,2", "bbox=0,0,1,1&polygon=0,0;1,0;1,1", "bbox=-180,-90,180,90", "bbox=1,1,0,0", "radius=10"} {
+		if status, _ := ts.do(t, http.MethodGet, "/v1/api/places/nearby?"+query, "", nil); status != http.StatusBadRequest {
+			t.Errorf("%q: got status %d; want %d", query, status, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestUpdatePlace(t *testing.T) {
+	ts := newTestServer(t)
+	ts.insertPlace(t, newPlace("place-1", "owner", "Java House"))
+
+	tests := []struct {
+		name  string
+		token string
+		body  map[string]interface{}
+		want  int
+	}{
+		{"owner", testToken(t, "owner", ""), map[string]interface{}{"title": "Java House Westlands"}, http.StatusOK},
+		{"other user", testToken(t, "other", ""), map[string]interface{}{"title": "Mine"}, http.StatusForbidden},
+		{"moderator", testToken(t, "moderator", "moderator"), map[string]interface{}{"title": "Moderated"}, http.StatusOK},
+		{"admin", testToken(t, "admin", "admin"), map[string]interface{}{"title": "Java House"}, http.StatusOK},
+		{"invalid", testToken(t, "owner", ""), map[string]interface{}{"email": "not an email"}, http.StatusUnprocessableEntity},
+		{"unauthenticated", "", map[string]interface{}{"title": "Anonymous"}, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := ts.do(t, http.MethodPatch, "/v1/api/places/place-1", tt.token, tt.body)
+			if status != tt.want {
+				t.Fatalf("got status %d; want %d: %v", status, tt.want, body)
+			}
+			if status != http.StatusOK {
+				return
+			}
+			place, err := ts.app.Models.Place.FindOne(context.Background(), ts.app.Config.DB.Database, ts.app.Config.DB.Collections.Places, "place-1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if place.Title != tt.body["title"] {
+				t.Errorf("got title %q; want %q", place.Title, tt.body["title"])
+			}
+		})
+	}
+}
+
+func TestDeletePlace(t *testing.T) {
+	ts := newTestServer(t)
+	ts.insertPlace(t, newPlace("place-1", "owner", "Java House"))
+	ts.insertPlace(t, newPlace("place-2", "owner", "Java House"))
+
+	if status, _ := ts.do(t, http.MethodDelete, "/v1/api/places/place-1", testToken(t, "other", ""), nil); status != http.StatusForbidden {
+		t.Errorf("other user: got status %d; want %d", status, http.StatusForbidden)
+	}
+	if status, _ := ts.do(t, http.MethodDelete, "/v1/api/places/place-1", testToken(t, "moderator", "moderator"), nil); status != http.StatusForbidden {
+		t.Errorf("moderator: got status %d; want %d", status, http.StatusForbidden)
+	}
+	if status, _ := ts.do(t, http.MethodDelete, "/v1/api/places/place-1", testToken(t, "owner", ""), nil); status != http.StatusOK {
+		t.Errorf("owner: got status %d; want %d", status, http.StatusOK)
+	}
+	if status, _ := ts.do(t, http.MethodDelete, "/v1/api/places/place-2", testToken(t, "admin", "admin"), nil); status != http.StatusOK {
+		t.Errorf("admin: got status %d; want %d", status, http.StatusOK)
+	}
+	if _, err := ts.app.Models.Place.FindOne(context.Background(), ts.app.Config.DB.Database, ts.app.Config.DB.Collections.Places, "place-1"); !errors.Is(err, data.ErrNoDocument) {
+		t.Errorf("got error %v; want the place to be deleted", err)
+	}
+}
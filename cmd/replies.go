@@ -0,0 +1,119 @@
+package main
+
+import (
+	"time"
+
+	"github.com/evansopilo/trouver/internal/authz"
+	"github.com/evansopilo/trouver/internal/data"
+	"github.com/evansopilo/trouver/internal/validator"
+	"github.com/gofiber/fiber/v2"
+)
+
+// PutReply creates or replaces the reply to a review, handler for the owner of the reviewed place responding publicly
+// to the review. A status created is returned for a new reply.
+func (app *Application) PutReply(c *fiber.Ctx) error {
+
+	// the request context carries the deadline of the route, the database operations are cancelled once it
+	// elapses or the server is shut down.
+	ctx := c.UserContext()
+
+	review, err := app.replyReview(c)
+	if err != nil {
+		return err
+	}
+
+	var reply data.Reply
+
+	// decode the request body to reply variable declared and continue with the request flow
+	// when the decode is successfull otherwise return a status bad request back to the client.
+	if err := c.BodyParser(&reply); err != nil {
+		app.logger(c).Error(err)
+		return fiber.NewError(fiber.StatusBadRequest, "invalid request")
+	}
+
+	// validate the reply and report any validation error with a status unprocessable entity.
+	if err := validator.ValidateReply(&reply); err != nil {
+		return err
+	}
+
+	// the reply is written by the authenticated user, an edit keeps the create time of the reply.
+	now := time.Now()
+	reply.UserID = c.Locals("user_id").(string)
+	reply.CreatedAt, reply.UpdatedAt = now, time.Time{}
+	status := fiber.StatusCreated
+	if review.Reply != nil {
+		reply.CreatedAt, reply.UpdatedAt = review.Reply.CreatedAt, now
+		status = fiber.StatusOK
+	}
+
+	// set the reply of the review record within the defined context with timeout. The error of the operation is sent
+	// back by the error handler ie. a status conflict when the review was updated by another request since it was read.
+	if err := app.Models.Review.SetReply(ctx, app.Config.DB.Database, app.Config.DB.Collections.Reviews, review.ID, review.Version, &reply); err != nil {
+		return ifMatchConflict(c, err)
+	}
+
+	c.Set(fiber.HeaderETag, entityTag(review.Version+1))
+	return c.Status(status).JSON(fiber.Map{
+		"status":  "success",
+		"message": "reply review success",
+		"data": map[string]interface{}{
+			"reply": reply,
+		},
+	})
+}
+
+// DeleteReply deletes the reply to a review, handler for the owner of the reviewed place removing their response.
+func (app *Application) DeleteReply(c *fiber.Ctx) error {
+
+	// the request context carries the deadline of the route, the database operations are cancelled once it
+	// elapses or the server is shut down.
+	ctx := c.UserContext()
+
+	review, err := app.replyReview(c)
+	if err != nil {
+		return err
+	}
+	if review.Reply == nil {
+		return fiber.NewError(fiber.StatusNotFound, "the review has no reply")
+	}
+
+	// remove the reply of the review record within the defined context with timeout. The error of the operation is
+	// sent back by the error handler.
+	if err := app.Models.Review.SetReply(ctx, app.Config.DB.Database, app.Config.DB.Collections.Reviews, review.ID, review.Version, nil); err != nil {
+		return ifMatchConflict(c, err)
+	}
+
+	c.Set(fiber.HeaderETag, entityTag(review.Version+1))
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":  "success",
+		"message": "delete reply success",
+		"data": map[string]interface{}{
+			"id": review.ID,
+		},
+	})
+}
+
+// replyReview returns the review the request replies to. The user must be granted the review:reply permission on the
+// reviewed place by the application policy, and the If-Match header of the request must match the review version.
+func (app *Application) replyReview(c *fiber.Ctx) (*data.Review, error) {
+	ctx := c.UserContext()
+
+	review, err := app.Models.Review.FindOne(ctx, app.Config.DB.Database, app.Config.DB.Collections.Reviews, c.Params("review_id"))
+	if err != nil {
+		return nil, err
+	}
+
+	// the owner of a reply is the owner of the reviewed place rather than the author of the review.
+	place, err := app.Models.Place.FindOne(ctx, app.Config.DB.Database, app.Config.DB.Collections.Places, review.PlaceID)
+	if err != nil {
+		return nil, err
+	}
+	if !app.can(c, authz.ReviewReply, place.UserID) {
+		return nil, app.forbidden(c, "permission denied")
+	}
+
+	if err := checkIfMatch(c, review.Version); err != nil {
+		return nil, err
+	}
+	return review, nil
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/evansopilo/trouver/internal/data"
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestReply(t *testing.T) {
+	ts := newTestServer(t)
+	ts.insertPlace(t, newPlace("place-1", "owner", "Java House"))
+	ts.insertReview(t, data.Review{ID: "review-1", PlaceID: "place-1", UserID: "author", TextContent: "Slow service.", Rating: 2})
+	owner := testToken(t, "owner", "")
+	reply := map[string]interface{}{"text": "Sorry, we have hired more staff."}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"review author", testToken(t, "author", "")},
+		{"other user", testToken(t, "other", "")},
+		{"moderator", testToken(t, "moderator", "moderator")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status, _ := ts.do(t, http.MethodPut, "/v1/api/reviews/review-1/reply", tt.token, reply); status != http.StatusForbidden {
+				t.Errorf("got status %d; want %d", status, http.StatusForbidden)
+			}
+		})
+	}
+
+	status, body := ts.do(t, http.MethodPut, "/v1/api/reviews/review-1/reply", owner, map[string]interface{}{"text": ""})
+	if errs, _ := body["errors"].(map[string]interface{}); status != http.StatusUnprocessableEntity || errs["text"] == nil {
+		t.Errorf("empty reply: got status %d and body %v; want %d with a text error", status, body, http.StatusUnprocessableEntity)
+	}
+	if status, _ := ts.do(t, http.MethodPut, "/v1/api/reviews/missing/reply", owner, reply); status != http.StatusNotFound {
+		t.Errorf("missing review: got status %d; want %d", status, http.StatusNotFound)
+	}
+
+	if status, body := ts.do(t, http.MethodPut, "/v1/api/reviews/review-1/reply", owner, reply); status != http.StatusCreated {
+		t.Fatalf("create: got status %d; want %d: %v", status, http.StatusCreated, body)
+	}
+
+	// the reply is returned inline with the review.
+	_, body = ts.do(t, http.MethodGet, "/v1/api/places/place-1/reviews", "", nil)
+	first := body["data"].([]interface{})[0].(map[string]interface{})
+	created, _ := first["reply"].(map[string]interface{})
+	if created["text"] != reply["text"] || created["user_id"] != "owner" || created["created_at"] == nil {
+		t.Fatalf("list: got reply %v; want the reply of the owner", first["reply"])
+	}
+
+	if status, header := ts.send(t, http.MethodPut, "/v1/api/reviews/review-1/reply", `{"text": "Sorry, we are hiring."}`, map[string]string{
+		fiber.HeaderAuthorization: "Bearer " + owner,
+		fiber.HeaderIfMatch:       `"2"`,
+	}); status != http.StatusOK || header.Get(fiber.HeaderETag) != `"3"` {
+		t.Fatalf("edit: got status %d and etag %q; want %d and %q", status, header.Get(fiber.HeaderETag), http.StatusOK, `"3"`)
+	}
+	_, body = ts.do(t, http.MethodGet, "/v1/api/reviews/review-1", "", nil)
+	edited, _ := dataOf(t, body)["review"].(map[string]interface{})["reply"].(map[string]interface{})
+	if edited["text"] != "Sorry, we are hiring." || edited["created_at"] != created["created_at"] || edited["updated_at"] == created["updated_at"] {
+		t.Errorf("edit: got reply %v; want the edited reply with its create time", edited)
+	}
+
+	// the author of the review can't change the reply.
+	if status, _ := ts.do(t, http.MethodPatch, "/v1/api/reviews/review-1", testToken(t, "author", ""), map[string]interface{}{
+		"reply": map[string]interface{}{"text": "Great place!"},
+	}); status != http.StatusOK {
+		t.Fatalf("patch review: got status %d; want %d", status, http.StatusOK)
+	}
+	_, body = ts.do(t, http.MethodGet, "/v1/api/reviews/review-1", "", nil)
+	if got := dataOf(t, body)["review"].(map[string]interface{})["reply"].(map[string]interface{})["text"]; got != "Sorry, we are hiring." {
+		t.Errorf("patch review: got reply text %v; want it unchanged", got)
+	}
+
+	if status, _ := ts.do(t, http.MethodDelete, "/v1/api/reviews/review-1/reply", testToken(t, "admin", "admin"), nil); status != http.StatusOK {
+		t.Errorf("delete: got status %d; want %d", status, http.StatusOK)
+	}
+	if status, _ := ts.do(t, http.MethodDelete, "/v1/api/reviews/review-1/reply", owner, nil); status != http.StatusNotFound {
+		t.Errorf("delete twice: got status %d; want %d", status, http.StatusNotFound)
+	}
+	_, body = ts.do(t, http.MethodGet, "/v1/api/reviews/review-1", "", nil)
+	if got := dataOf(t, body)["review"].(map[string]interface{})["reply"]; got != nil {
+		t.Errorf("got reply %v after the delete; want none", got)
+	}
+}
+
+func TestCreateReviewReply(t *testing.T) {
+	ts := newTestServer(t)
+	ts.insertPlace(t, newPlace("place-1", "owner", "Java House"))
+
+	// a reply sent with a new review is not taken as the reply of the owner.
+	status, body := ts.do(t, http.MethodPost, "/v1/api/reviews", testToken(t, "author", ""), map[string]interface{}{
+		"place_id": "place-1",
+		"rating":   5,
+		"reply":    map[string]interface{}{"user_id": "owner", "text": "Thank you!"},
+	})
+	if status != http.StatusCreated {
+		t.Fatalf("got status %d; want %d: %v", status, http.StatusCreated, body)
+	}
+	_, body = ts.do(t, http.MethodGet, "/v1/api/reviews/"+dataOf(t, body)["id"].(string), "", nil)
+	if reply := dataOf(t, body)["review"].(map[string]interface{})["reply"]; reply != nil {
+		t.Errorf("got reply %v; want none", reply)
+	}
+}
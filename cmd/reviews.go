@@ -1,54 +1,71 @@
 package main
 
 import (
-	"context"
-	"strconv"
+	"errors"
 	"time"
 
+	"github.com/evansopilo/trouver/internal/authz"
 	"github.com/evansopilo/trouver/internal/data"
+	"github.com/evansopilo/trouver/internal/validator"
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
-	"github.com/sirupsen/logrus"
 )
 
 // CreateReview creates a new review, handler for adding a new review to the application.
 func (app *Application) CreateReview(c *fiber.Ctx) error {
 
-	// create a context with a 5-second timeout deadline. The entire request response cycle will be
-	// tied to this context, therefore response should be returned within the defined context timeout.
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+	// the request context carries the deadline of the route, the database operations are cancelled once it
+	// elapses or the server is shut down.
+	ctx := c.UserContext()
 
 	var review data.Review
 
 	// decode the request body to review variable declared and continue with the request flow
 	// when the decode is successfull otherwise return a status bad request back to the client.
 	if err := c.BodyParser(&review); err != nil {
-		logrus.Println(err)
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"status":  "error",
-			"message": "invalid request",
-		})
+		app.logger(c).Error(err)
+		return fiber.NewError(fiber.StatusBadRequest, "invalid request")
 	}
 
 	// add review user id to user id obtained from auth token claims.
 	review.UserID = c.Locals("user_id").(string)
 
+	// the reply is written by the owner of the place with SetReply and the tombstone by the trash, clear any sent by
+	// the client.
+	review.Reply = nil
+	review.Tombstone = data.Tombstone{}
+
+	// the reviewed place must exist, any other failure to read the place is sent back by the error handler.
+	placeExists := false
+	if review.PlaceID != "" {
+		_, err := app.Models.Place.FindOne(ctx, app.Config.DB.Database, app.Config.DB.Collections.Places, review.PlaceID)
+		if err != nil && !errors.Is(err, data.ErrNoDocument) {
+			return err
+		}
+		placeExists = err == nil
+	}
+
+	// validate the review and report any validation error with a status unprocessable entity.
+	if err := validator.ValidateReview(&review, placeExists); err != nil {
+		return err
+	}
+
 	// add timestamp of current time to the create time of review object.
 	review.CreatedAt = time.Now()
 
 	// add review id from a random generated uuid.
 	review.ID = uuid.New().String()
 
-	// insert the new review record to the database within the defined context with timeout. When the
-	// operation fails for any reason ie. elapsed context timeout reponse with a 500 Internal Server error
-	// is returned.
-	if err := app.Models.Review.InsertOne(ctx, "trouver", "reviews", &review); err != nil {
-		logrus.Println(err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"status":  "error",
-			"message": "create review failed",
-		})
+	// insert the new review record to the database within the defined context with timeout. A user reviews a place
+	// once, a status conflict is returned back to the client for a second review, the deleted review of the user
+	// is kept in the trash until it is restored or purged. Any other error of the operation is sent back by the error
+	// handler.
+	err := app.Models.Review.InsertOne(ctx, app.Config.DB.Database, app.Config.DB.Collections.Reviews, &review)
+	if errors.Is(err, data.ErrConflict) {
+		return fiber.NewError(fiber.StatusConflict, "the place was already reviewed by the user, update the review or ask for the deleted review to be restored")
+	}
+	if err != nil {
+		return err
 	}
 
 	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
@@ -63,84 +80,147 @@ func (app *Application) CreateReview(c *fiber.Ctx) error {
 // ListPlace lists places, handler from updating a place in the application.
 func (app *Application) ListReview(c *fiber.Ctx) error {
 
-	// create a context with a 5-second timeout deadline. The entire request response cycle will be
-	// tied to this context, therefore response should be returned within the defined context timeout.
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	page, _ := strconv.Atoi(c.Query("page", "1"))
-	page_size, _ := strconv.Atoi(c.Query("size", "10"))
-	skip := (page-1)*page_size + 1
-
-	// get review records with the provided filters from the database within the defined context with timeout.When the
-	// operation fails for any reason ie. elapsed context timeout reponse with a 500 Internal Server error
-	// is returned.
-	reviews, err := app.Models.Review.List(ctx, "trouver", "reviews", c.Params("place_id"), data.Filter{Skip: skip, Limit: page_size})
+	// the request context carries the deadline of the route, the database operations are cancelled once it
+	// elapses or the server is shut down.
+	ctx := c.UserContext()
+
+	var filter data.Filter
+	page_size, err := app.readPage(c, &filter)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	// get review records with the provided filters from the database within the defined context with timeout. The error of the
+	// operation is sent back by the error handler ie. a status service unavailable when the database can't be reached.
+	reviews, err := app.Models.Review.List(ctx, app.Config.DB.Database, app.Config.DB.Collections.Reviews, c.Params("place_id"), filter)
 	if err != nil {
-		logrus.Println(err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"status":  "error",
-			"message": "read review failed",
-		})
+		return err
+	}
+
+	// the extra review over the page size tells that there are more pages.
+	metadata := data.Metadata{PageSize: page_size}
+	if len(*reviews) > page_size {
+		*reviews = (*reviews)[:page_size]
+		last := (*reviews)[page_size-1]
+		metadata.HasMore = true
+		metadata.NextCursor = app.nextCursor(last.CreatedAt, last.ID)
+	}
+
+	// the total is only counted for the page numbered listings, clients paging with the cursor don't need it.
+	if filter.After == nil {
+		total, err := app.Models.Review.Count(ctx, app.Config.DB.Database, app.Config.DB.Collections.Reviews, c.Params("place_id"), filter)
+		if err != nil {
+			return err
+		}
+		metadata.Total = &total
 	}
+
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
-		"status": "success",
-		"data":   reviews,
+		"status":   "success",
+		"data":     reviews,
+		"metadata": metadata,
 	})
 }
 
-func (app *Application) UpdateReview(c *fiber.Ctx) error {
+// GetReview gets review, handler for getting a review from the application by given id.
+func (app *Application) GetReview(c *fiber.Ctx) error {
 
-	// create a context with a 5-second timeout deadline. The entire request response cycle will be
-	// tied to this context, therefore response should be returned within the defined context timeout.
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+	// the request context carries the deadline of the route, the database operations are cancelled once it
+	// elapses or the server is shut down.
+	ctx := c.UserContext()
 
-	var review data.Review
+	// get review record with the provided id from the database within the defined context with timeout. The error of
+	// the operation is sent back by the error handler ie. a status not found when the document doesn't exist.
+	review, err := app.Models.Review.FindOne(ctx, app.Config.DB.Database, app.Config.DB.Collections.Reviews, c.Params("review_id"))
+	if err != nil {
+		return err
+	}
 
-	// add review id to id obtained from prams
-	review.ID = c.Params("review_id")
+	// the entity tag of the review is its version, a status not modified is returned back to the client when it
+	// already has this version of the review.
+	if notModified(c, review.Version) {
+		return nil
+	}
 
-	// add user id to user id obtained from auth token claims.
-	review.UserID = c.Locals("user_id").(string)
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":  "success",
+		"message": "read review operation success",
+		"data": map[string]interface{}{
+			"review": review,
+		},
+	})
+}
 
-	// decode the request body to review variable declared and continue with the request flow
-	// when the decode is successfull otherwise return a status bad request back to the client.
-	if err := c.BodyParser(&review); err != nil {
-		logrus.Println(err)
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"status":  "error",
-			"message": "invalid user request",
-		})
+// ReviewHistory lists the revisions of a review, handler for getting the previous versions of a review from the
+// application by given id, from the oldest.
+func (app *Application) ReviewHistory(c *fiber.Ctx) error {
+
+	// the request context carries the deadline of the route, the database operations are cancelled once it
+	// elapses or the server is shut down.
+	ctx := c.UserContext()
+
+	// get the revisions of the review with the provided id from the database within the defined context with timeout.
+	// The error of the operation is sent back by the error handler ie. a status not found when the review doesn't exist.
+	revisions, err := app.Models.Review.History(ctx, app.Config.DB.Database, app.Config.DB.Collections.Reviews, c.Params("review_id"))
+	if err != nil {
+		return err
 	}
 
-	existingReview, err := app.Models.Review.FindOne(ctx, "trouver", "reviews", c.Params("review_id"))
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status": "success",
+		"data":   revisions,
+	})
+}
+
+func (app *Application) UpdateReview(c *fiber.Ctx) error {
+
+	// the request context carries the deadline of the route, the database operations are cancelled once it
+	// elapses or the server is shut down.
+	ctx := c.UserContext()
+
+	existingReview, err := app.Models.Review.FindOne(ctx, app.Config.DB.Database, app.Config.DB.Collections.Reviews, c.Params("review_id"))
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"status":  "error",
-			"message": "update place failed",
-		})
+		return err
 	}
 
-	// for successfull update, the place to be updated must be specific to the user or the user must be an admin.
+	// for successfull update, the user must be granted the review:update permission on the review by the application policy,
 	// otherwise returns a status forbidden(user has no permission to update the record).
-	if !(existingReview.UserID == c.Locals("user_id").(string) || c.Locals("user_role").(string) == "admin") {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
-			"status":  "error",
-			"message": "update place failed",
-		})
+	if !app.can(c, authz.ReviewUpdate, existingReview.UserID) {
+		return app.forbidden(c, "permission denied")
+	}
+
+	// the update is made from the version of the review given by the If-Match header, otherwise a status precondition
+	// failed is returned so that the changes of another client are not overwritten.
+	if err := checkIfMatch(c, existingReview.Version); err != nil {
+		return err
 	}
 
-	// update the place record to the database within the defined context with timeout. When the
-	// operation fails for any reason ie. elapsed context timeout reponse with a 500 Internal Server error
-	// is returned.
-	if err := app.Models.Review.UpdateOne(ctx, "trouver", "places", &review); err != nil {
-		logrus.Println(err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"status":  "error",
-			"message": "update place failed",
-		})
+	// apply the merge patch or json patch of the request body to the existing review, only the fields given by the
+	// patch are changed and the fields set to null are removed. A review can't be moved to another place or user,
+	// any change of its id, place, user, create time, version or reply by the patch is discarded.
+	var review data.Review
+	if err := applyPatch(c, existingReview, &review); err != nil {
+		return err
 	}
+	review.ID, review.PlaceID, review.UserID, review.CreatedAt = existingReview.ID, existingReview.PlaceID, existingReview.UserID, existingReview.CreatedAt
+	review.Version, review.Reply = existingReview.Version, existingReview.Reply
+
+	// validate the review as it will be after the update, the place of the review can't change so it is known to exist.
+	if err := validator.ValidateReview(&review, true); err != nil {
+		return err
+	}
+
+	// add timestamp of current time to the update time of review object.
+	review.UpdatedAt = time.Now()
 
+	// update the review record to the database within the defined context with timeout. The error of the
+	// operation is sent back by the error handler ie. a status not found when the document doesn't exist, or a
+	// status conflict when the review was updated by another request since it was read.
+	if err := app.Models.Review.UpdateOne(ctx, app.Config.DB.Database, app.Config.DB.Collections.Reviews, &review); err != nil {
+		return ifMatchConflict(c, err)
+	}
+
+	c.Set(fiber.HeaderETag, entityTag(review.Version))
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"status":  "success",
 		"message": "update review success",
@@ -152,39 +232,34 @@ func (app *Application) UpdateReview(c *fiber.Ctx) error {
 
 func (app *Application) DeleteReview(c *fiber.Ctx) error {
 
-	// create a context with a 5-second timeout deadline. The entire request response cycle will be
-	// tied to this context, therefore response should be returned within the defined context timeout.
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+	// the request context carries the deadline of the route, the database operations are cancelled once it
+	// elapses or the server is shut down.
+	ctx := c.UserContext()
 
 	// get the review with the provided id from the database.
-	existingReview, err := app.Models.Review.FindOne(ctx, "trouver", "reviews", c.Params("review_id"))
+	existingReview, err := app.Models.Review.FindOne(ctx, app.Config.DB.Database, app.Config.DB.Collections.Reviews, c.Params("review_id"))
 	if err != nil {
-		logrus.Println(err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"status":  "error",
-			"message": "delete review failed",
-		})
+		return err
 	}
 
-	// for successfull delete, the review to be delete must be specific to the user or the user must be an admin.
+	// for successfull delete, the user must be granted the review:delete permission on the review by the application policy,
 	// otherwise returns a status forbidden(user has no permission to delete the record).
-	if !(existingReview.UserID == c.Locals("user_id").(string) || c.Locals("user_role") == "admin") {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
-			"status":  "error",
-			"message": "update review failed",
-		})
-	}
-
-	// delete the review record to the database within the defined context with timeout. When the
-	// operation fails for any reason ie. elapsed context timeout reponse with a 500 Internal Server error
-	// is returned.
-	if err := app.Models.Review.DeleteOne(ctx, "trouver", "reviews", c.Params("review_id")); err != nil {
-		logrus.Println(err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"status":  "error",
-			"message": "delete review failed",
-		})
+	if !app.can(c, authz.ReviewDelete, existingReview.UserID) {
+		return app.forbidden(c, "permission denied")
+	}
+
+	// the delete is made from the version of the review given by the If-Match header, otherwise a status precondition
+	// failed is returned.
+	if err := checkIfMatch(c, existingReview.Version); err != nil {
+		return err
+	}
+
+	// move the review record to the trash within the defined context with timeout, it can be restored by an admin until
+	// it is purged once the trash retention elapsed. The error of the operation is sent back by the error handler ie. a
+	// status not found when the document doesn't exist, or a status conflict when the review was written by another
+	// request since it was read.
+	if err := app.Models.Review.DeleteOne(ctx, app.Config.DB.Database, app.Config.DB.Collections.Reviews, existingReview.ID, existingReview.Version, c.Locals("user_id").(string)); err != nil {
+		return ifMatchConflict(c, err)
 	}
 
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/evansopilo/trouver/internal/data"
+)
+
+func TestCreateReview(t *testing.T) {
+	ts := newTestServer(t)
+	ts.insertPlace(t, newPlace("place-1", "owner", "Java House"))
+
+	for i, rating := range []float32{5, 4, 4} {
+		status, body := ts.do(t, http.MethodPost, "/v1/api/reviews", testToken(t, fmt.Sprintf("user-%d", i+1), ""), map[string]interface{}{
+			"place_id": "place-1",
+			"title":    "Great coffee.",
+			"rating":   rating,
+		})
+		if status != http.StatusCreated {
+			t.Fatalf("got status %d; want %d: %v", status, http.StatusCreated, body)
+		}
+	}
+
+	// a user reviews a place once.
+	token := testToken(t, "user-1", "")
+	if status, _ := ts.do(t, http.MethodPost, "/v1/api/reviews", token, map[string]interface{}{
+		"place_id": "place-1",
+		"rating":   1,
+	}); status != http.StatusConflict {
+		t.Errorf("second review: got status %d; want %d", status, http.StatusConflict)
+	}
+
+	place, err := ts.app.Models.Place.FindOne(context.Background(), ts.app.Config.DB.Database, ts.app.Config.DB.Collections.Places, "place-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if place.ReviewCount != 3 || place.AverageRating != 4.33 {
+		t.Errorf("got %d reviews with %v average rating; want 3 reviews with 4.33", place.ReviewCount, place.AverageRating)
+	}
+	if got, want := place.RatingHistogram, []int64{0, 0, 0, 2, 1}; len(got) != 5 || got[3] != want[3] || got[4] != want[4] {
+		t.Errorf("got histogram %v; want %v", got, want)
+	}
+
+	tests := []struct {
+		name   string
+		review map[string]interface{}
+		field  string
+	}{
+		{"missing place", map[string]interface{}{"place_id": "missing", "rating": 3}, "place_id"},
+		{"rating too high", map[string]interface{}{"place_id": "place-1", "rating": 6}, "rating"},
+		{"missing rating", map[string]interface{}{"place_id": "place-1"}, "rating"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := ts.do(t, http.MethodPost, "/v1/api/reviews", token, tt.review)
+			if status != http.StatusUnprocessableEntity {
+				t.Fatalf("got status %d; want %d", status, http.StatusUnprocessableEntity)
+			}
+			if errs, _ := body["errors"].(map[string]interface{}); errs[tt.field] == nil {
+				t.Errorf("missing error for field %q in %v", tt.field, body)
+			}
+		})
+	}
+}
+
+func TestListReview(t *testing.T) {
+	ts := newTestServer(t)
+	ts.insertPlace(t, newPlace("place-1", "owner", "Java House"))
+	ts.insertPlace(t, newPlace("place-2", "owner", "Artcaffe"))
+	now := time.Now()
+	for i, id := range []string{"review-1", "review-2", "review-3"} {
+		ts.insertReview(t, data.Review{ID: id, PlaceID: "place-1", UserID: fmt.Sprintf("user-%d", i+1), Rating: 4, CreatedAt: now.Add(time.Duration(i) * time.Minute)})
+	}
+	ts.insertReview(t, data.Review{ID: "review-4", PlaceID: "place-2", UserID: "user-1", Rating: 4})
+
+	status, body := ts.do(t, http.MethodGet, "/v1/api/places/place-1/reviews?size=2", "", nil)
+	if status != http.StatusOK {
+		t.Fatalf("got status %d; want %d", status, http.StatusOK)
+	}
+	if got, want := idsOf(t, body), []string{"review-3", "review-2"}; !equalStrings(got, want) {
+		t.Errorf("got reviews %v; want %v", got, want)
+	}
+	metadata := body["metadata"].(map[string]interface{})
+	if metadata["has_more"] != true || metadata["total"] != float64(3) {
+		t.Errorf("unexpected metadata %v", metadata)
+	}
+
+	cursor := url.QueryEscape(metadata["next_cursor"].(string))
+	_, body = ts.do(t, http.MethodGet, "/v1/api/places/place-1/reviews?size=2&cursor="+cursor, "", nil)
+	if got, want := idsOf(t, body), []string{"review-1"}; !equalStrings(got, want) {
+		t.Errorf("got reviews %v; want %v", got, want)
+	}
+	// the total is not counted for the listings paged with the cursor.
+	if metadata := body["metadata"].(map[string]interface{}); metadata["has_more"] != false || metadata["total"] != nil || metadata["next_cursor"] != nil {
+		t.Errorf("unexpected metadata %v", metadata)
+	}
+
+	// the cursor is only valid for the secret it was signed with.
+	ts.app.Config.Cursor.Secret = "rotated"
+	if status, _ := ts.do(t, http.MethodGet, "/v1/api/places/place-1/reviews?size=2&cursor="+cursor, "", nil); status != http.StatusBadRequest {
+		t.Errorf("rotated secret: got status %d; want %d", status, http.StatusBadRequest)
+	}
+
+	if status, _ := ts.do(t, http.MethodGet, "/v1/api/places/place-1/reviews?cursor=forged", "", nil); status != http.StatusBadRequest {
+		t.Errorf("forged cursor: got status %d; want %d", status, http.StatusBadRequest)
+	}
+}
+
+func TestUpdateReview(t *testing.T) {
+	ts := newTestServer(t)
+	ts.insertPlace(t, newPlace("place-1", "owner", "Java House"))
+	ts.insertPlace(t, newPlace("place-2", "owner", "Artcaffe"))
+	ts.insertReview(t, data.Review{ID: "review-1", PlaceID: "place-1", UserID: "author", Rating: 2})
+
+	tests := []struct {
+		name  string
+		token string
+		body  map[string]interface{}
+		want  int
+	}{
+		{"author", testToken(t, "author", ""), map[string]interface{}{"rating": 4, "place_id": "place-2"}, http.StatusOK},
+		{"other user", testToken(t, "other", ""), map[string]interface{}{"rating": 1}, http.StatusForbidden},
+		{"moderator", testToken(t, "moderator", "moderator"), map[string]interface{}{"title": "Moderated."}, http.StatusOK},
+		{"invalid", testToken(t, "author", ""), map[string]interface{}{"rating": 10}, http.StatusUnprocessableEntity},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status, body := ts.do(t, http.MethodPatch, "/v1/api/reviews/review-1", tt.token, tt.body); status != tt.want {
+				t.Fatalf("got status %d; want %d: %v", status, tt.want, body)
+			}
+		})
+	}
+
+	review, err := ts.app.Models.Review.FindOne(context.Background(), ts.app.Config.DB.Database, ts.app.Config.DB.Collections.Reviews, "review-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if review.Rating != 4 || review.PlaceID != "place-1" || review.UserID != "author" || review.TextContent != "Moderated." {
+		t.Errorf("unexpected review %+v", review)
+	}
+	place, err := ts.app.Models.Place.FindOne(context.Background(), ts.app.Config.DB.Database, ts.app.Config.DB.Collections.Places, "place-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if place.AverageRating != 4 {
+		t.Errorf("got average rating %v; want 4", place.AverageRating)
+	}
+}
+
+func TestDeleteReview(t *testing.T) {
+	ts := newTestServer(t)
+	ts.insertPlace(t, newPlace("place-1", "owner", "Java House"))
+	ts.insertReview(t, data.Review{ID: "review-1", PlaceID: "place-1", UserID: "author", Rating: 2})
+	ts.insertReview(t, data.Review{ID: "review-2", PlaceID: "place-1", UserID: "other-author", Rating: 4})
+
+	if status, _ := ts.do(t, http.MethodDelete, "/v1/api/reviews/review-1", testToken(t, "other", ""), nil); status != http.StatusForbidden {
+		t.Errorf("other user: got status %d; want %d", status, http.StatusForbidden)
+	}
+	if status, _ := ts.do(t, http.MethodDelete, "/v1/api/reviews/review-1", testToken(t, "author", ""), nil); status != http.StatusOK {
+		t.Errorf("author: got status %d; want %d", status, http.StatusOK)
+	}
+	if status, _ := ts.do(t, http.MethodDelete, "/v1/api/reviews/review-2", testToken(t, "moderator", "moderator"), nil); status != http.StatusOK {
+		t.Errorf("moderator: got status %d; want %d", status, http.StatusOK)
+	}
+
+	place, err := ts.app.Models.Place.FindOne(context.Background(), ts.app.Config.DB.Database, ts.app.Config.DB.Collections.Places, "place-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if place.ReviewCount != 0 || place.AverageRating != 0 {
+		t.Errorf("got %d reviews with %v average rating; want no reviews", place.ReviewCount, place.AverageRating)
+	}
+
+	// the reviews in the trash are kept when their authors review the place again, the moderated review can still
+	// be restored.
+	for _, userID := range []string{"author", "other-author"} {
+		if status, _ := ts.do(t, http.MethodPost, "/v1/api/reviews", testToken(t, userID, ""), map[string]interface{}{
+			"place_id": "place-1",
+			"rating":   5,
+		}); status != http.StatusConflict {
+			t.Errorf("%s reviews again: got status %d; want %d", userID, status, http.StatusConflict)
+		}
+	}
+	admin := testToken(t, "admin", "admin")
+	if _, body := ts.do(t, http.MethodGet, "/v1/api/admin/trash/reviews", admin, nil); len(idsOf(t, body)) != 2 {
+		t.Errorf("trash: got reviews %v; want 2", idsOf(t, body))
+	}
+	if status, _ := ts.do(t, http.MethodPost, "/v1/api/admin/trash/reviews/review-2/restore", admin, nil); status != http.StatusOK {
+		t.Errorf("restore moderated: got status %d; want %d", status, http.StatusOK)
+	}
+}
+
+func TestReviewHistory(t *testing.T) {
+	ts := newTestServer(t)
+	ts.insertPlace(t, newPlace("place-1", "owner", "Java House"))
+	ts.insertReview(t, data.Review{ID: "review-1", PlaceID: "place-1", UserID: "author", TextContent: "Slow service.", Rating: 2})
+	token := testToken(t, "author", "")
+
+	for _, update := range []map[string]interface{}{{"rating": 4}, {"title": "Better service."}} {
+		if status, body := ts.do(t, http.MethodPatch, "/v1/api/reviews/review-1", token, update); status != http.StatusOK {
+			t.Fatalf("update: got status %d; want %d: %v", status, http.StatusOK, body)
+		}
+	}
+
+	status, body := ts.do(t, http.MethodGet, "/v1/api/reviews/review-1/history", "", nil)
+	if status != http.StatusOK {
+		t.Fatalf("got status %d; want %d", status, http.StatusOK)
+	}
+	revisions, _ := body["data"].([]interface{})
+	if len(revisions) != 2 {
+		t.Fatalf("got revisions %v; want 2", revisions)
+	}
+	for i, want := range []map[string]interface{}{
+		{"version": float64(1), "title": "Slow service.", "rating": float64(2)},
+		{"version": float64(2), "title": "Slow service.", "rating": float64(4)},
+	} {
+		revision := revisions[i].(map[string]interface{})
+		for key, value := range want {
+			if revision[key] != value {
+				t.Errorf("revision %d: got %s %v; want %v", i, key, revision[key], value)
+			}
+		}
+	}
+
+	if status, _ := ts.do(t, http.MethodGet, "/v1/api/reviews/missing/history", "", nil); status != http.StatusNotFound {
+		t.Errorf("missing review: got status %d; want %d", status, http.StatusNotFound)
+	}
+
+	// the revisions are purged with the review, which the user can then write again.
+	ctx := context.Background()
+	db, reviews := ts.app.Config.DB.Database, ts.app.Config.DB.Collections.Reviews
+	if err := ts.app.Models.Review.DeleteOne(ctx, db, reviews, "review-1", 3, "author"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ts.app.Models.Review.Purge(ctx, db, reviews, time.Now().Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	ts.insertReview(t, data.Review{ID: "review-1", PlaceID: "place-1", UserID: "author", Rating: 5})
+	if _, body := ts.do(t, http.MethodGet, "/v1/api/reviews/review-1/history", "", nil); len(body["data"].([]interface{})) != 0 {
+		t.Errorf("got revisions %v after the purge; want none", body["data"])
+	}
+}
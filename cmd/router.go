@@ -1,22 +1,82 @@
 package main
 
-import "github.com/gofiber/fiber/v2"
+import (
+	"context"
+	"time"
+
+	"github.com/evansopilo/trouver/internal/authz"
+	"github.com/evansopilo/trouver/internal/ratelimit"
+	"github.com/gofiber/fiber/v2"
+)
+
+// prefix is the path prefix of the API routes.
+const prefix = "/v1/api"
 
 func (app *Application) Router() *fiber.App {
-	api := fiber.New()
-	v1 := api.Group("/v1/api")
+	app.requests, app.cancelRequests = context.WithCancel(context.Background())
+	read, write, auth := app.rateLimiters()
+
+	api := fiber.New(fiber.Config{ErrorHandler: app.ErrorHandler})
+	api.Use(app.RequestContext, app.RequestID, app.Instrument, app.LogRequest, app.Trace)
+	api.Get("/metrics", app.ServeMetrics())
+
+	v1 := api.Group(prefix)
+
+	// route registers the handlers of the route behind the middleware setting the deadline of the route.
+	route := func(method, path string, handlers ...fiber.Handler) {
+		timeout := app.Timeout(app.routeTimeout(method, prefix+path))
+		v1.Add(method, path, append([]fiber.Handler{timeout}, handlers...)...)
+	}
 	{
-		v1.Get("/health", app.Health)
-		v1.Post("/places", app.CreatePlace)
-		v1.Get("/places/:place_id", app.GetPlace)
-		v1.Get("/places", app.ListPlace)
-		v1.Patch("/places/:place_id", app.UpdateOne)
-		v1.Delete("/places/:place_id", app.DeletePlace)
-
-		v1.Post("/reviews", app.CreateReview)
-		v1.Get("/places/:place_id/reviews", app.ListReview)
-		v1.Patch("/reviews/:review_id", app.UpdateReview)
-		v1.Delete("/reviews/:review_id", app.DeleteReview)
+		route(fiber.MethodGet, "/health", app.Health)
+		route(fiber.MethodGet, "/health/live", app.Health)
+		route(fiber.MethodGet, "/health/ready", app.Ready)
+		route(fiber.MethodPost, "/places", auth, app.Authenticate, write, app.Authorize(authz.PlaceCreate), app.CreatePlace)
+		route(fiber.MethodGet, "/places/search", read, app.SearchPlace)
+		route(fiber.MethodGet, "/places/nearby", read, app.NearbyPlace)
+		route(fiber.MethodGet, "/places/:place_id", read, app.GetPlace)
+		route(fiber.MethodGet, "/places", read, app.ListPlace)
+		route(fiber.MethodPatch, "/places/:place_id", auth, app.Authenticate, write, app.Authorize(authz.PlaceUpdate), app.UpdateOne)
+		route(fiber.MethodDelete, "/places/:place_id", auth, app.Authenticate, write, app.Authorize(authz.PlaceDelete), app.DeletePlace)
+
+		route(fiber.MethodPost, "/reviews", auth, app.Authenticate, write, app.Authorize(authz.ReviewCreate), app.CreateReview)
+		route(fiber.MethodGet, "/places/:place_id/reviews", read, app.ListReview)
+		route(fiber.MethodGet, "/reviews/:review_id", read, app.GetReview)
+		route(fiber.MethodGet, "/reviews/:review_id/history", read, app.ReviewHistory)
+		route(fiber.MethodPatch, "/reviews/:review_id", auth, app.Authenticate, write, app.Authorize(authz.ReviewUpdate), app.UpdateReview)
+		route(fiber.MethodDelete, "/reviews/:review_id", auth, app.Authenticate, write, app.Authorize(authz.ReviewDelete), app.DeleteReview)
+		route(fiber.MethodPut, "/reviews/:review_id/reply", auth, app.Authenticate, write, app.Authorize(authz.ReviewReply), app.PutReply)
+		route(fiber.MethodDelete, "/reviews/:review_id/reply", auth, app.Authenticate, write, app.Authorize(authz.ReviewReply), app.DeleteReply)
+
+		route(fiber.MethodGet, "/admin/trash/places", auth, app.Authenticate, read, app.Authorize(authz.TrashRead), app.ListTrashPlaces)
+		route(fiber.MethodGet, "/admin/trash/reviews", auth, app.Authenticate, read, app.Authorize(authz.TrashRead), app.ListTrashReviews)
+		route(fiber.MethodPost, "/admin/trash/places/:place_id/restore", auth, app.Authenticate, write, app.Authorize(authz.PlaceRestore), app.RestorePlace)
+		route(fiber.MethodPost, "/admin/trash/reviews/:review_id/restore", auth, app.Authenticate, write, app.Authorize(authz.ReviewRestore), app.RestoreReview)
 	}
 	return api
 }
+
+// routeTimeout returns the deadline of the route given by its method and path pattern, the default deadline is
+// returned when the route has none configured.
+func (app *Application) routeTimeout(method, path string) time.Duration {
+	if timeout, ok := app.Config.Timeouts.Routes[method+" "+path]; ok {
+		return timeout
+	}
+	return app.Config.Timeouts.Default
+}
+
+// rateLimiters returns the rate limit middlewares of the read, write and authenticated routes, each with its own
+// budget. The write middleware is placed after Authenticate so that the writes are limited per user rather than per
+// IP address, the auth middleware is placed before Authenticate so that the requests with a missing or invalid token
+// are limited per IP address before they are rejected. When the limiter is disabled the middlewares pass the
+// requests through.
+func (app *Application) rateLimiters() (read, write, auth fiber.Handler) {
+	if !app.Config.Limiter.Enabled {
+		next := func(c *fiber.Ctx) error { return c.Next() }
+		return next, next, next
+	}
+	read = app.RateLimit(ratelimit.New(app.Config.Limiter.RPS, app.Config.Limiter.Burst))
+	write = app.RateLimit(ratelimit.New(app.Config.Limiter.WriteRPS, app.Config.Limiter.WriteBurst))
+	auth = app.RateLimit(ratelimit.New(app.Config.Limiter.AuthRPS, app.Config.Limiter.AuthBurst))
+	return read, write, auth
+}
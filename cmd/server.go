@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// errShutdownTimeout is returned by serve when the in-flight requests are not drained within the shutdown timeout.
+var errShutdownTimeout = errors.New("shutdown timeout exceeded before the in-flight requests were drained")
+
+// serve starts the HTTP server and blocks until the context is done, then shuts the server down gracefully. The
+// server is first marked as draining so that the readiness check reports it unavailable for the drain delay, then it
+// stops accepting new connections and waits for the in-flight requests up to the configured shutdown timeout, and
+// finally the database connections are closed. An error is returned when the server fails to listen or the shutdown is not clean.
+func (app *Application) serve(ctx context.Context) error {
+	api := app.Router()
+	api.Hooks().OnListen(func() error {
+		app.Logger.WithField("port", app.Config.Server.Port).Info("starting server")
+		return nil
+	})
+
+	// purge the trash in the background until the server shuts down.
+	purgeCtx, stopPurge := context.WithCancel(ctx)
+	purged := make(chan struct{})
+	go func() {
+		defer close(purged)
+		app.purgeTrash(purgeCtx)
+	}()
+	defer func() {
+		stopPurge()
+		<-purged
+	}()
+
+	listenErr := make(chan error, 1)
+	go func() {
+		listenErr <- api.Listen(fmt.Sprintf(":%v", app.Config.Server.Port))
+	}()
+
+	select {
+	case err := <-listenErr:
+		// the server failed to start, there are no requests to drain.
+		if closeErr := app.close(context.Background()); closeErr != nil {
+			app.Logger.Error(closeErr)
+		}
+		return err
+	case <-ctx.Done():
+	}
+
+	app.Logger.Info("shutting down server")
+	app.draining.Store(true)
+
+	// keep serving the requests until the load balancers saw the failing readiness check and stopped routing new
+	// requests to the server.
+	time.Sleep(app.Config.Server.DrainDelay)
+
+	// create a context with the shutdown timeout deadline, shared by the draining of the requests and the closing
+	// of the database connections.
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), app.Config.Server.ShutdownTimeout)
+	defer cancel()
+
+	shutdownErr := make(chan error, 1)
+	go func() { shutdownErr <- api.Shutdown() }()
+
+	var err error
+	select {
+	case err = <-shutdownErr:
+	case <-shutdownCtx.Done():
+		// abort the database operations of the requests still in flight.
+		app.cancelRequests()
+		err = errShutdownTimeout
+	}
+	if err == nil {
+		err = <-listenErr
+	}
+
+	if closeErr := app.close(shutdownCtx); closeErr != nil {
+		if err != nil {
+			app.Logger.Error(closeErr)
+		} else {
+			err = closeErr
+		}
+	}
+	if err == nil {
+		app.Logger.Info("stopped server")
+	}
+	return err
+}
+
+// close releases the connections held by the application, the mongo client is disconnected and the traces and
+// the logs are flushed.
+func (app *Application) close(ctx context.Context) error {
+	var err error
+	if app.client != nil {
+		err = app.client.Disconnect(ctx)
+	}
+	if app.flushTraces != nil {
+		if flushErr := app.flushTraces(ctx); flushErr != nil && err == nil {
+			err = flushErr
+		}
+	}
+	if f, ok := app.Logger.Out.(*os.File); ok {
+		// syncing is not supported by every file, e.g. a terminal, therefore the error is ignored.
+		_ = f.Sync()
+	}
+	return err
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestServe(t *testing.T) {
+	ts := newTestServer(t)
+
+	// pick a free port for the server.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.app.Config.Server.Port = fmt.Sprint(ln.Addr().(*net.TCPAddr).Port)
+	ln.Close()
+
+	ts.app.Config.Server.DrainDelay = 500 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	served := make(chan error, 1)
+	go func() { served <- ts.app.serve(ctx) }()
+
+	url := fmt.Sprintf("http://127.0.0.1:%s/v1/api/health", ts.app.Config.Server.Port)
+	var res *http.Response
+	for i := 0; i < 50; i++ {
+		if res, err = http.Get(url); err == nil {
+			res.Body.Close()
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("got status %d; want %d", res.StatusCode, http.StatusOK)
+	}
+
+	// the server still serves the requests during the drain delay, the readiness check reports it unavailable.
+	cancel()
+	ready := fmt.Sprintf("http://127.0.0.1:%s/v1/api/health/ready", ts.app.Config.Server.Port)
+	for i := 0; i < 10 && !ts.app.draining.Load(); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if res, err := http.Get(ready); err != nil {
+		t.Errorf("got error %v during the drain delay", err)
+	} else {
+		res.Body.Close()
+		if res.StatusCode != http.StatusServiceUnavailable {
+			t.Errorf("ready while draining: got status %d; want %d", res.StatusCode, http.StatusServiceUnavailable)
+		}
+	}
+
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Fatalf("got error %v on shutdown", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("server did not shut down")
+	}
+	if !ts.app.draining.Load() {
+		t.Error("server is not marked as draining after the shutdown")
+	}
+	if _, err := http.Get(url); err == nil {
+		t.Error("server still accepts connections after the shutdown")
+	}
+}
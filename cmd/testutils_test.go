@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/evansopilo/trouver/internal/authz"
+	"github.com/evansopilo/trouver/internal/data"
+	"github.com/evansopilo/trouver/internal/logging"
+	"github.com/evansopilo/trouver/internal/metrics"
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const testSecret = "test-secret"
+
+// testServer is the router of an application backed by the in-memory models, authenticating the requests with
+// HS256 tokens signed with the test secret.
+type testServer struct {
+	app    *Application
+	router *fiber.App
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+
+	cfg := defaultConfig()
+	cfg.Version = "test"
+	cfg.Server.Env = "testing"
+	cfg.DB.Driver = "memory"
+	cfg.Cursor.Secret = testSecret
+	cfg.Auth.Provider = "jwt"
+	cfg.Auth.JWT.Secret = testSecret
+	cfg.Limiter.Enabled = false
+	if err := cfg.validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	logger := logging.New(cfg.Server.Env)
+	logger.SetOutput(io.Discard)
+
+	app := &Application{Config: cfg, Logger: logger, Policy: authz.DefaultPolicy, Metrics: metrics.New(cfg.App, cfg.Version)}
+	if err := app.initModels(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.initAuth(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return &testServer{app: app, router: app.Router()}
+}
+
+// testToken signs a token for the user with the given role, an empty role leaves out the role claim.
+func testToken(t *testing.T, userID, role string) string {
+	t.Helper()
+	claims := jwt.MapClaims{"sub": userID, "exp": time.Now().Add(time.Hour).Unix()}
+	if role != "" {
+		claims["role"] = role
+	}
+	return signToken(t, claims)
+}
+
+// signToken signs a token with the claims by the secret of the test server.
+func signToken(t *testing.T, claims jwt.MapClaims) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(testSecret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// do sends the request with the token and the JSON encoded body when they are set, and returns the response status
+// code and the decoded JSON response body.
+func (ts *testServer) do(t *testing.T, method, path, token string, body interface{}) (int, map[string]interface{}) {
+	t.Helper()
+
+	var reqBody io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reqBody = bytes.NewReader(b)
+	}
+	req := httptest.NewRequest(method, path, reqBody)
+	if body != nil {
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	}
+	if token != "" {
+		req.Header.Set(fiber.HeaderAuthorization, "Bearer "+token)
+	}
+
+	res, err := ts.router.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	var resBody map[string]interface{}
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b) > 0 {
+		if err := json.Unmarshal(b, &resBody); err != nil {
+			t.Fatalf("invalid JSON response body %q: %v", b, err)
+		}
+	}
+	return res.StatusCode, resBody
+}
+
+// send sends the request with the headers to the test server and returns the response status and headers.
+func (ts *testServer) send(t *testing.T, method, path, body string, headers map[string]string) (int, http.Header) {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	res, err := ts.router.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	return res.StatusCode, res.Header
+}
+
+// insertPlace inserts the place fixture directly in the place model.
+func (ts *testServer) insertPlace(t *testing.T, place data.Place) {
+	t.Helper()
+	if place.CreatedAt.IsZero() {
+		place.CreatedAt = time.Now()
+	}
+	if err := ts.app.Models.Place.InsertOne(context.Background(), ts.app.Config.DB.Database, ts.app.Config.DB.Collections.Places, &place); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// insertReview inserts the review fixture directly in the review model.
+func (ts *testServer) insertReview(t *testing.T, review data.Review) {
+	t.Helper()
+	if review.CreatedAt.IsZero() {
+		review.CreatedAt = time.Now()
+	}
+	if err := ts.app.Models.Review.InsertOne(context.Background(), ts.app.Config.DB.Database, ts.app.Config.DB.Collections.Reviews, &review); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// newPlace returns a valid place fixture owned by the user.
+func newPlace(id, userID, title string) data.Place {
+	place := data.Place{ID: id, UserID: userID, Title: title, Description: "A place to test."}
+	return place
+}
+
+// dataOf returns the "data" object of a response body.
+func dataOf(t *testing.T, body map[string]interface{}) map[string]interface{} {
+	t.Helper()
+	d, ok := body["data"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("response body has no data object: %v", body)
+	}
+	return d
+}
+
+// idsOf returns the ids of the documents in the "data" list of a response body.
+func idsOf(t *testing.T, body map[string]interface{}) []string {
+	t.Helper()
+	list, _ := body["data"].([]interface{})
+	ids := make([]string, 0, len(list))
+	for _, item := range list {
+		ids = append(ids, item.(map[string]interface{})["id"].(string))
+	}
+	return ids
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
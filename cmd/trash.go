@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/evansopilo/trouver/internal/data"
+	"github.com/gofiber/fiber/v2"
+)
+
+// ListTrashPlaces lists the deleted places, handler for the admins listing the places in the trash with the time
+// they were deleted at and the user who deleted them.
+func (app *Application) ListTrashPlaces(c *fiber.Ctx) error {
+
+	// the request context carries the deadline of the route, the database operations are cancelled once it
+	// elapses or the server is shut down.
+	ctx := c.UserContext()
+
+	filter := data.Filter{Deleted: true}
+	page_size, err := app.readPage(c, &filter)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	// get the place records in the trash from the database within the defined context with timeout. The error of the
+	// operation is sent back by the error handler.
+	places, err := app.Models.Place.List(ctx, app.Config.DB.Database, app.Config.DB.Collections.Places, filter)
+	if err != nil {
+		return err
+	}
+
+	// the extra place over the page size tells that there are more pages.
+	metadata := data.Metadata{PageSize: page_size}
+	if len(*places) > page_size {
+		*places = (*places)[:page_size]
+		last := (*places)[page_size-1]
+		metadata.HasMore = true
+		metadata.NextCursor = app.nextCursor(last.CreatedAt, last.ID)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":   "success",
+		"data":     places,
+		"metadata": metadata,
+	})
+}
+
+// ListTrashReviews lists the deleted reviews, handler for the admins listing the reviews of every place in the trash.
+func (app *Application) ListTrashReviews(c *fiber.Ctx) error {
+
+	// the request context carries the deadline of the route, the database operations are cancelled once it
+	// elapses or the server is shut down.
+	ctx := c.UserContext()
+
+	filter := data.Filter{Deleted: true}
+	page_size, err := app.readPage(c, &filter)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	// get the review records in the trash from the database within the defined context with timeout, the empty place
+	// id matches the reviews of every place. The error of the operation is sent back by the error handler.
+	reviews, err := app.Models.Review.List(ctx, app.Config.DB.Database, app.Config.DB.Collections.Reviews, "", filter)
+	if err != nil {
+		return err
+	}
+
+	// the extra review over the page size tells that there are more pages.
+	metadata := data.Metadata{PageSize: page_size}
+	if len(*reviews) > page_size {
+		*reviews = (*reviews)[:page_size]
+		last := (*reviews)[page_size-1]
+		metadata.HasMore = true
+		metadata.NextCursor = app.nextCursor(last.CreatedAt, last.ID)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":   "success",
+		"data":     reviews,
+		"metadata": metadata,
+	})
+}
+
+// RestorePlace restores place, handler for the admins restoring a place from the trash.
+func (app *Application) RestorePlace(c *fiber.Ctx) error {
+
+	// the request context carries the deadline of the route, the database operations are cancelled once it
+	// elapses or the server is shut down.
+	ctx := c.UserContext()
+
+	// restore the place record from the trash within the defined context with timeout. The error of the operation is
+	// sent back by the error handler ie. a status not found when the place is not in the trash.
+	if err := app.Models.Place.Restore(ctx, app.Config.DB.Database, app.Config.DB.Collections.Places, c.Params("place_id")); err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":  "success",
+		"message": "restore place success",
+		"data": map[string]interface{}{
+			"id": c.Params("place_id"),
+		},
+	})
+}
+
+// RestoreReview restores review, handler for the admins restoring a review from the trash.
+func (app *Application) RestoreReview(c *fiber.Ctx) error {
+
+	// the request context carries the deadline of the route, the database operations are cancelled once it
+	// elapses or the server is shut down.
+	ctx := c.UserContext()
+
+	// restore the review record from the trash within the defined context with timeout. The error of the operation is
+	// sent back by the error handler ie. a status not found when the review is not in the trash.
+	err := app.Models.Review.Restore(ctx, app.Config.DB.Database, app.Config.DB.Collections.Reviews, c.Params("review_id"))
+	if errors.Is(err, data.ErrConflict) {
+		return fiber.NewError(fiber.StatusConflict, "the place of the review is in the trash, restore the place first")
+	}
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":  "success",
+		"message": "restore review success",
+		"data": map[string]interface{}{
+			"id": c.Params("review_id"),
+		},
+	})
+}
+
+// purgeTrash purges the trash every purge interval until the context is done, the places and reviews deleted for
+// longer than the trash retention are deleted for good.
+func (app *Application) purgeTrash(ctx context.Context) {
+	ticker := time.NewTicker(app.Config.Trash.PurgeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			app.purgeOnce(ctx)
+		}
+	}
+}
+
+// purgeOnce deletes the places and reviews moved to the trash before the trash retention for good, the failures are
+// logged and the purge is retried on the next interval.
+func (app *Application) purgeOnce(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	before := time.Now().Add(-app.Config.Trash.Retention)
+	places, err := app.Models.Place.Purge(ctx, app.Config.DB.Database, app.Config.DB.Collections.Places, before)
+	if err != nil {
+		app.Logger.WithError(err).Error("purge of the places trash failed")
+	}
+	reviews, err := app.Models.Review.Purge(ctx, app.Config.DB.Database, app.Config.DB.Collections.Reviews, before)
+	if err != nil {
+		app.Logger.WithError(err).Error("purge of the reviews trash failed")
+	}
+	if places > 0 || reviews > 0 {
+		app.Logger.WithField("places", places).WithField("reviews", reviews).Info("trash purged")
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/evansopilo/trouver/internal/data"
+)
+
+func TestTrashPlace(t *testing.T) {
+	ts := newTestServer(t)
+	place := newPlace("place-1", "owner", "Java House")
+	place.Description = "Coffee and breakfast."
+	ts.insertPlace(t, place)
+	ts.insertPlace(t, newPlace("place-2", "owner", "Tea Room"))
+	admin := testToken(t, "admin", "admin")
+
+	if status, _ := ts.do(t, http.MethodDelete, "/v1/api/places/place-1", testToken(t, "owner", ""), nil); status != http.StatusOK {
+		t.Fatalf("delete: got status %d; want %d", status, http.StatusOK)
+	}
+
+	// the deleted place is hidden from the reads, the listing and the search.
+	if status, _ := ts.do(t, http.MethodGet, "/v1/api/places/place-1", "", nil); status != http.StatusNotFound {
+		t.Errorf("get: got status %d; want %d", status, http.StatusNotFound)
+	}
+	if _, body := ts.do(t, http.MethodGet, "/v1/api/places", "", nil); !equalStrings(idsOf(t, body), []string{"place-2"}) {
+		t.Errorf("list: got places %v; want [place-2]", idsOf(t, body))
+	}
+	if _, body := ts.do(t, http.MethodGet, "/v1/api/places/search?q=coffee", "", nil); len(idsOf(t, body)) != 0 {
+		t.Errorf("search: got places %v; want none", idsOf(t, body))
+	}
+
+	// only the admins see the trash.
+	if status, _ := ts.do(t, http.MethodGet, "/v1/api/admin/trash/places", testToken(t, "owner", ""), nil); status != http.StatusForbidden {
+		t.Errorf("trash as user: got status %d; want %d", status, http.StatusForbidden)
+	}
+	status, body := ts.do(t, http.MethodGet, "/v1/api/admin/trash/places", admin, nil)
+	if status != http.StatusOK || !equalStrings(idsOf(t, body), []string{"place-1"}) {
+		t.Fatalf("trash: got status %d and places %v; want %d and [place-1]", status, idsOf(t, body), http.StatusOK)
+	}
+	if deletedBy := body["data"].([]interface{})[0].(map[string]interface{})["deleted_by"]; deletedBy != "owner" {
+		t.Errorf("trash: got deleted_by %v; want owner", deletedBy)
+	}
+
+	if status, _ := ts.do(t, http.MethodPost, "/v1/api/admin/trash/places/place-1/restore", testToken(t, "owner", ""), nil); status != http.StatusForbidden {
+		t.Errorf("restore as user: got status %d; want %d", status, http.StatusForbidden)
+	}
+	if status, _ := ts.do(t, http.MethodPost, "/v1/api/admin/trash/places/place-1/restore", admin, nil); status != http.StatusOK {
+		t.Fatalf("restore: got status %d; want %d", status, http.StatusOK)
+	}
+	if status, _ := ts.do(t, http.MethodPost, "/v1/api/admin/trash/places/place-1/restore", admin, nil); status != http.StatusNotFound {
+		t.Errorf("restore twice: got status %d; want %d", status, http.StatusNotFound)
+	}
+	if status, body := ts.do(t, http.MethodGet, "/v1/api/places/place-1", "", nil); status != http.StatusOK || dataOf(t, body)["place"].(map[string]interface{})["deleted_at"] != nil {
+		t.Errorf("get restored: got status %d and body %v; want %d without deleted_at", status, body, http.StatusOK)
+	}
+}
+
+func TestTrashReview(t *testing.T) {
+	ts := newTestServer(t)
+	ts.insertPlace(t, newPlace("place-1", "owner", "Java House"))
+	ts.insertReview(t, data.Review{ID: "review-1", PlaceID: "place-1", UserID: "author", Rating: 2})
+	ts.insertReview(t, data.Review{ID: "review-2", PlaceID: "place-1", UserID: "other", Rating: 4})
+	admin := testToken(t, "admin", "admin")
+
+	if status, _ := ts.do(t, http.MethodDelete, "/v1/api/reviews/review-1", testToken(t, "author", ""), nil); status != http.StatusOK {
+		t.Fatalf("delete: got status %d; want %d", status, http.StatusOK)
+	}
+	if _, body := ts.do(t, http.MethodGet, "/v1/api/places/place-1/reviews", "", nil); !equalStrings(idsOf(t, body), []string{"review-2"}) {
+		t.Errorf("list: got reviews %v; want [review-2]", idsOf(t, body))
+	}
+	// the deleted review no longer counts in the rating of the place.
+	if _, body := ts.do(t, http.MethodGet, "/v1/api/places/place-1", "", nil); dataOf(t, body)["place"].(map[string]interface{})["review_count"] != float64(1) {
+		t.Errorf("got place %v; want 1 review", dataOf(t, body)["place"])
+	}
+
+	if _, body := ts.do(t, http.MethodGet, "/v1/api/admin/trash/reviews", admin, nil); !equalStrings(idsOf(t, body), []string{"review-1"}) {
+		t.Errorf("trash: got reviews %v; want [review-1]", idsOf(t, body))
+	}
+	if status, _ := ts.do(t, http.MethodPost, "/v1/api/admin/trash/reviews/review-1/restore", admin, nil); status != http.StatusOK {
+		t.Fatalf("restore: got status %d; want %d", status, http.StatusOK)
+	}
+	if _, body := ts.do(t, http.MethodGet, "/v1/api/places/place-1/reviews", "", nil); len(idsOf(t, body)) != 2 {
+		t.Errorf("list restored: got reviews %v; want 2", idsOf(t, body))
+	}
+}
+
+func TestPurgeTrash(t *testing.T) {
+	ts := newTestServer(t)
+	ts.insertPlace(t, newPlace("place-1", "owner", "Java House"))
+	ts.insertPlace(t, newPlace("place-2", "owner", "Tea Room"))
+	ctx := context.Background()
+	places := ts.app.Models.Place
+	db, coll := ts.app.Config.DB.Database, ts.app.Config.DB.Collections.Places
+
+	for _, id := range []string{"place-1", "place-2"} {
+		if err := places.DeleteOne(ctx, db, coll, id, 1, "owner"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// nothing was in the trash longer than the retention.
+	ts.app.purgeOnce(ctx)
+	if n, err := places.Count(ctx, db, coll, data.Filter{Deleted: true}); err != nil || n != 2 {
+		t.Fatalf("got %d places in the trash (err %v); want 2", n, err)
+	}
+
+	ts.app.Config.Trash.Retention = time.Nanosecond
+	time.Sleep(time.Millisecond)
+	ts.app.purgeOnce(ctx)
+	if n, err := places.Count(ctx, db, coll, data.Filter{Deleted: true}); err != nil || n != 0 {
+		t.Errorf("got %d places in the trash (err %v); want 0", n, err)
+	}
+	if err := places.Restore(ctx, db, coll, "place-1"); !errors.Is(err, data.ErrNoDocument) {
+		t.Errorf("restore purged: got error %v; want %v", err, data.ErrNoDocument)
+	}
+}
+
+func TestTrashPlaceReviews(t *testing.T) {
+	ts := newTestServer(t)
+	ts.insertPlace(t, newPlace("place-1", "owner", "Java House"))
+	ts.insertReview(t, data.Review{ID: "review-1", PlaceID: "place-1", UserID: "author", Rating: 2})
+	ts.insertReview(t, data.Review{ID: "review-2", PlaceID: "place-1", UserID: "other", Rating: 4})
+	admin := testToken(t, "admin", "admin")
+
+	// review-2 is deleted before its place, it stays in the trash when the place is restored.
+	if status, _ := ts.do(t, http.MethodDelete, "/v1/api/reviews/review-2", testToken(t, "other", ""), nil); status != http.StatusOK {
+		t.Fatalf("delete review: got status %d; want %d", status, http.StatusOK)
+	}
+	if status, _ := ts.do(t, http.MethodDelete, "/v1/api/places/place-1", testToken(t, "owner", ""), nil); status != http.StatusOK {
+		t.Fatalf("delete place: got status %d; want %d", status, http.StatusOK)
+	}
+	if status, _ := ts.do(t, http.MethodGet, "/v1/api/reviews/review-1", "", nil); status != http.StatusNotFound {
+		t.Errorf("get review of deleted place: got status %d; want %d", status, http.StatusNotFound)
+	}
+	if _, body := ts.do(t, http.MethodGet, "/v1/api/admin/trash/reviews", admin, nil); len(idsOf(t, body)) != 2 {
+		t.Errorf("trash: got reviews %v; want 2", idsOf(t, body))
+	}
+
+	// the reviews of a place in the trash can't be created or restored.
+	if status, _ := ts.do(t, http.MethodPost, "/v1/api/reviews", testToken(t, "author", ""), map[string]interface{}{
+		"place_id": "place-1",
+		"rating":   5,
+	}); status != http.StatusUnprocessableEntity {
+		t.Errorf("create review of deleted place: got status %d; want %d", status, http.StatusUnprocessableEntity)
+	}
+	if err := ts.app.Models.Review.InsertOne(context.Background(), ts.app.Config.DB.Database, ts.app.Config.DB.Collections.Reviews, &data.Review{ID: "review-3", PlaceID: "place-1", UserID: "author", Rating: 5}); !errors.Is(err, data.ErrValidation) {
+		t.Errorf("insert review of deleted place: got error %v; want %v", err, data.ErrValidation)
+	}
+	if status, _ := ts.do(t, http.MethodPost, "/v1/api/admin/trash/reviews/review-1/restore", admin, nil); status != http.StatusConflict {
+		t.Errorf("restore review of deleted place: got status %d; want %d", status, http.StatusConflict)
+	}
+
+	if status, _ := ts.do(t, http.MethodPost, "/v1/api/admin/trash/places/place-1/restore", admin, nil); status != http.StatusOK {
+		t.Fatalf("restore place: got status %d; want %d", status, http.StatusOK)
+	}
+	if _, body := ts.do(t, http.MethodGet, "/v1/api/places/place-1/reviews", "", nil); !equalStrings(idsOf(t, body), []string{"review-1"}) {
+		t.Errorf("list restored: got reviews %v; want [review-1]", idsOf(t, body))
+	}
+	if _, body := ts.do(t, http.MethodGet, "/v1/api/admin/trash/reviews", admin, nil); !equalStrings(idsOf(t, body), []string{"review-2"}) {
+		t.Errorf("trash restored: got reviews %v; want [review-2]", idsOf(t, body))
+	}
+}
+
+func TestCreateTrashed(t *testing.T) {
+	ts := newTestServer(t)
+	token := testToken(t, "owner", "")
+
+	// the tombstone is maintained by the trash, a tombstone sent by the client is ignored.
+	status, body := ts.do(t, http.MethodPost, "/v1/api/places", token, map[string]interface{}{
+		"title":       "Java House",
+		"description": "Coffee and breakfast.",
+		"deleted_at":  time.Now(),
+		"deleted_by":  "owner",
+		"version":     7,
+	})
+	if status != http.StatusCreated {
+		t.Fatalf("create place: got status %d; want %d", status, http.StatusCreated)
+	}
+	id := dataOf(t, body)["id"].(string)
+	status, body = ts.do(t, http.MethodGet, "/v1/api/places/"+id, "", nil)
+	if status != http.StatusOK {
+		t.Fatalf("get place: got status %d; want %d", status, http.StatusOK)
+	}
+	if place := dataOf(t, body)["place"].(map[string]interface{}); place["deleted_at"] != nil || place["version"] != float64(1) {
+		t.Errorf("get place: got %v; want version 1 without deleted_at", place)
+	}
+
+	if status, _ := ts.do(t, http.MethodPost, "/v1/api/reviews", token, map[string]interface{}{
+		"place_id":   id,
+		"rating":     5,
+		"deleted_at": time.Now(),
+		"deleted_by": "owner",
+	}); status != http.StatusCreated {
+		t.Fatalf("create review: got status %d; want %d", status, http.StatusCreated)
+	}
+	if _, body := ts.do(t, http.MethodGet, "/v1/api/places/"+id+"/reviews", "", nil); len(idsOf(t, body)) != 1 {
+		t.Errorf("list reviews: got reviews %v; want 1", idsOf(t, body))
+	}
+}
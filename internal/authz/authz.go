@@ -0,0 +1,102 @@
+package authz
+
+// Permission is an action that can be performed on a resource, in the "resource:action" format.
+type Permission string
+
+const (
+	PlaceCreate  Permission = "place:create"
+	PlaceUpdate  Permission = "place:update"
+	PlaceDelete  Permission = "place:delete"
+	ReviewCreate Permission = "review:create"
+	ReviewUpdate Permission = "review:update"
+	ReviewDelete Permission = "review:delete"
+
+	// ReviewReply replies to the reviews, the owner of a reply permission is the owner of the reviewed place.
+	ReviewReply Permission = "review:reply"
+
+	// TrashRead, PlaceRestore and ReviewRestore manage the trash of the deleted places and reviews.
+	TrashRead     Permission = "trash:read"
+	PlaceRestore  Permission = "place:restore"
+	ReviewRestore Permission = "review:restore"
+
+	// All grants every permission.
+	All Permission = "*"
+)
+
+// Roles of the users as set in the "role" claim of the id token.
+const (
+	RoleUser          = "user"
+	RoleBusinessOwner = "business-owner"
+	RoleModerator     = "moderator"
+	RoleAdmin         = "admin"
+)
+
+// Rule lists the permissions granted to a role. Any permissions apply to every resource, while Own permissions
+// only apply to the resources owned by the user, that is the owner role of a resource.
+type Rule struct {
+	Any []Permission
+	Own []Permission
+}
+
+// Policy maps a role to the rule of permissions granted to it. Roles that are not in the policy have no permissions.
+type Policy map[string]Rule
+
+// userRule is the rule of the users, they can create places and reviews, manage their own and reply to the reviews
+// of their places.
+var userRule = Rule{
+	Any: []Permission{PlaceCreate, ReviewCreate},
+	Own: []Permission{PlaceUpdate, PlaceDelete, ReviewUpdate, ReviewDelete, ReviewReply},
+}
+
+// DefaultPolicy is the policy of the application roles. Every user can create places and reviews, manage their own
+// and reply to the reviews of their places, business owners are granted the same rule as the users, moderators can
+// additionally manage any review and update any place, admins can do everything including the management of the trash.
+var DefaultPolicy = Policy{
+	RoleUser:          userRule,
+	RoleBusinessOwner: userRule,
+	RoleModerator: {
+		Any: []Permission{PlaceCreate, PlaceUpdate, ReviewCreate, ReviewUpdate, ReviewDelete},
+		Own: []Permission{PlaceDelete},
+	},
+	RoleAdmin: {
+		Any: []Permission{All},
+	},
+}
+
+// Subject is the authenticated user performing an action.
+type Subject struct {
+	UserID string
+	Role   string
+}
+
+// Can reports whether the subject is granted the permission on a resource owned by the user with the given owner
+// id. An empty owner id is never matched, so that resources without an owner can only be managed with Any rules.
+func (p Policy) Can(subject Subject, permission Permission, ownerID string) bool {
+	rule, ok := p[subject.Role]
+	if !ok {
+		return false
+	}
+	if contains(rule.Any, permission) {
+		return true
+	}
+	return ownerID != "" && subject.UserID == ownerID && contains(rule.Own, permission)
+}
+
+// Allows reports whether the role is granted the permission on at least some resources, either on any resource or
+// on the ones owned by the user. It is used to reject requests before the resource is read.
+func (p Policy) Allows(role string, permission Permission) bool {
+	rule, ok := p[role]
+	if !ok {
+		return false
+	}
+	return contains(rule.Any, permission) || contains(rule.Own, permission)
+}
+
+func contains(permissions []Permission, permission Permission) bool {
+	for _, p := range permissions {
+		if p == permission || p == All {
+			return true
+		}
+	}
+	return false
+}
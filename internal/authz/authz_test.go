@@ -0,0 +1,57 @@
+package authz
+
+import "testing"
+
+func TestCan(t *testing.T) {
+	tests := []struct {
+		name       string
+		subject    Subject
+		permission Permission
+		ownerID    string
+		want       bool
+	}{
+		{"user creates", Subject{"user-1", RoleUser}, PlaceCreate, "", true},
+		{"user updates own place", Subject{"user-1", RoleUser}, PlaceUpdate, "user-1", true},
+		{"user updates other place", Subject{"user-1", RoleUser}, PlaceUpdate, "user-2", false},
+		{"user updates place without owner", Subject{"user-1", RoleUser}, PlaceUpdate, "", false},
+		{"user replies to own place", Subject{"user-1", RoleUser}, ReviewReply, "user-1", true},
+		{"user replies to other place", Subject{"user-1", RoleUser}, ReviewReply, "user-2", false},
+		{"business owner replies to own place", Subject{"user-1", RoleBusinessOwner}, ReviewReply, "user-1", true},
+		{"business owner replies to other place", Subject{"user-1", RoleBusinessOwner}, ReviewReply, "user-2", false},
+		{"moderator deletes any review", Subject{"user-1", RoleModerator}, ReviewDelete, "user-2", true},
+		{"moderator deletes other place", Subject{"user-1", RoleModerator}, PlaceDelete, "user-2", false},
+		{"moderator reads trash", Subject{"user-1", RoleModerator}, TrashRead, "", false},
+		{"admin restores", Subject{"user-1", RoleAdmin}, PlaceRestore, "", true},
+		{"unknown role", Subject{"user-1", "guest"}, PlaceCreate, "", false},
+		{"empty subject owns nothing", Subject{"", RoleUser}, PlaceUpdate, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DefaultPolicy.Can(tt.subject, tt.permission, tt.ownerID); got != tt.want {
+				t.Errorf("got %v; want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAllows(t *testing.T) {
+	tests := []struct {
+		role       string
+		permission Permission
+		want       bool
+	}{
+		{RoleUser, PlaceCreate, true},
+		{RoleUser, PlaceDelete, true},
+		{RoleUser, ReviewReply, true},
+		{RoleBusinessOwner, ReviewReply, true},
+		{RoleModerator, PlaceDelete, true},
+		{RoleModerator, ReviewRestore, false},
+		{RoleAdmin, TrashRead, true},
+		{"guest", ReviewCreate, false},
+	}
+	for _, tt := range tests {
+		if got := DefaultPolicy.Allows(tt.role, tt.permission); got != tt.want {
+			t.Errorf("%s %s: got %v; want %v", tt.role, tt.permission, got, tt.want)
+		}
+	}
+}
@@ -2,7 +2,6 @@ package data
 
 import (
 	"context"
-	"log"
 
 	firebase "firebase.google.com/go/v4"
 	"firebase.google.com/go/v4/auth"
@@ -21,12 +20,16 @@ type User struct {
 	Disabled      bool   `json:"disabled,omitempty"`
 }
 
+// AuthModel is the firebase backed implementation of the auth model.
 type AuthModel struct {
+	app *firebase.App
 }
 
-// VerifyIDToken verifys a token id, takes context, firebase app and id token.
-func (AuthModel) VerifyIDToken(ctx context.Context, app *firebase.App, idToken string) (*auth.Token, error) {
-	client, err := app.Auth(ctx)
+func NewAuthModel(app *firebase.App) *AuthModel { return &AuthModel{app: app} }
+
+// VerifyIDToken verifys a token id, takes context and id token.
+func (a AuthModel) VerifyIDToken(ctx context.Context, idToken string) (*auth.Token, error) {
+	client, err := a.app.Auth(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -37,9 +40,16 @@ func (AuthModel) VerifyIDToken(ctx context.Context, app *firebase.App, idToken s
 	return token, nil
 }
 
-// RevokeRefreshTokens revokes refresh token associated by a user account, takes context, firebase app and user uid.
-func (AuthModel) RevokeRefreshTokens(ctx context.Context, app *firebase.App, uid string) error {
-	client, err := app.Auth(ctx)
+// Check checks that the auth provider is configured to verify the id tokens, takes a context. The firebase auth
+// client can only be created once the project id is found in the credentials or the environment.
+func (a AuthModel) Check(ctx context.Context) error {
+	_, err := a.app.Auth(ctx)
+	return err
+}
+
+// RevokeRefreshTokens revokes refresh token associated by a user account, takes context and user uid.
+func (a AuthModel) RevokeRefreshTokens(ctx context.Context, uid string) error {
+	client, err := a.app.Auth(ctx)
 	if err != nil {
 		return err
 	}
@@ -49,9 +59,9 @@ func (AuthModel) RevokeRefreshTokens(ctx context.Context, app *firebase.App, uid
 	return nil
 }
 
-// GetUser gets user by id, takes context, firebase app and user uid.
-func (AuthModel) GetUser(ctx context.Context, app *firebase.App, uid string) (*auth.UserRecord, error) {
-	client, err := app.Auth(ctx)
+// GetUser gets user by id, takes context and user uid.
+func (a AuthModel) GetUser(ctx context.Context, uid string) (*auth.UserRecord, error) {
+	client, err := a.app.Auth(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -62,22 +72,22 @@ func (AuthModel) GetUser(ctx context.Context, app *firebase.App, uid string) (*a
 	return user, nil
 }
 
-// GetUserByEmail gets user by email, takes context, firebase app and email.
-func (AuthModel) GetUserByEmail(ctx context.Context, app *firebase.App, email string) (*auth.UserRecord, error) {
-	client, err := app.Auth(ctx)
+// GetUserByEmail gets user by email, takes context and email.
+func (a AuthModel) GetUserByEmail(ctx context.Context, email string) (*auth.UserRecord, error) {
+	client, err := a.app.Auth(ctx)
 	if err != nil {
 		return nil, err
 	}
 	user, err := client.GetUserByEmail(ctx, email)
 	if err != nil {
-		log.Fatalf("error getting user by email %s: %v\n", email, err)
+		return nil, err
 	}
 	return user, nil
 }
 
-// GetUserByPhone gets user by phone, takes context, firebase app and phone.
-func (AuthModel) GetUserByPhone(ctx context.Context, app *firebase.App, phone string) (*auth.UserRecord, error) {
-	client, err := app.Auth(ctx)
+// GetUserByPhone gets user by phone, takes context and phone.
+func (a AuthModel) GetUserByPhone(ctx context.Context, phone string) (*auth.UserRecord, error) {
+	client, err := a.app.Auth(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -88,9 +98,9 @@ func (AuthModel) GetUserByPhone(ctx context.Context, app *firebase.App, phone st
 	return user, nil
 }
 
-// CreateUser creates a new user, takes a context, firebase app and user object.
-func (AuthModel) CreateUser(ctx context.Context, app *firebase.App, user *User) (*auth.UserRecord, error) {
-	client, err := app.Auth(ctx)
+// CreateUser creates a new user, takes a context and user object.
+func (a AuthModel) CreateUser(ctx context.Context, user *User) (*auth.UserRecord, error) {
+	client, err := a.app.Auth(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -109,9 +119,9 @@ func (AuthModel) CreateUser(ctx context.Context, app *firebase.App, user *User)
 	return u, nil
 }
 
-// UpdateUser updates an existing user, takes context, firebase app and user object.
-func (AuthModel) UpdateUser(ctx context.Context, app *firebase.App, user *User) (*auth.UserRecord, error) {
-	client, err := app.Auth(ctx)
+// UpdateUser updates an existing user, takes context and user object.
+func (a AuthModel) UpdateUser(ctx context.Context, user *User) (*auth.UserRecord, error) {
+	client, err := a.app.Auth(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -130,9 +140,9 @@ func (AuthModel) UpdateUser(ctx context.Context, app *firebase.App, user *User)
 	return u, nil
 }
 
-// DeleteUser delete a user by id, takes a context, firebase app and user uid.
-func (AuthModel) DeleteUser(ctx context.Context, app *firebase.App, uid string) error {
-	client, err := app.Auth(ctx)
+// DeleteUser delete a user by id, takes a context and user uid.
+func (a AuthModel) DeleteUser(ctx context.Context, uid string) error {
+	client, err := a.app.Auth(ctx)
 	if err != nil {
 		return err
 	}
@@ -143,9 +153,9 @@ func (AuthModel) DeleteUser(ctx context.Context, app *firebase.App, uid string)
 	return nil
 }
 
-// CustomClaimsSet sets custom claims to a user, takes context firebase app, uid and claims map.
-func (AuthModel) CustomClaimsSet(ctx context.Context, app *firebase.App, uid string, claims map[string]interface{}) error {
-	client, err := app.Auth(ctx)
+// CustomClaimsSet sets custom claims to a user, takes context, uid and claims map.
+func (a AuthModel) CustomClaimsSet(ctx context.Context, uid string, claims map[string]interface{}) error {
+	client, err := a.app.Auth(ctx)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,85 @@
+package data
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCursor(t *testing.T) {
+	secret := []byte("secret")
+	cursor := Cursor{CreatedAt: time.Date(2022, 1, 1, 12, 0, 0, 0, time.UTC), ID: "place-1"}
+	token := cursor.Encode(secret)
+
+	decoded, err := DecodeCursor(token, secret)
+	if err != nil {
+		t.Fatalf("got error %v; want none", err)
+	}
+	if !decoded.CreatedAt.Equal(cursor.CreatedAt) || decoded.ID != cursor.ID {
+		t.Errorf("got cursor %+v; want %+v", decoded, cursor)
+	}
+
+	payload, signature, _ := strings.Cut(token, ".")
+	forged := base64.RawURLEncoding.EncodeToString([]byte(`{"t":"2022-01-01T12:00:00Z","id":"place-9"}`))
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"other secret", cursor.Encode([]byte("other"))},
+		{"forged payload", forged + "." + signature},
+		{"missing signature", payload},
+		{"empty signature", payload + "."},
+		{"malformed payload", "!!!." + signature},
+		{"malformed signature", payload + ".!!!"},
+		{"missing id", Cursor{CreatedAt: cursor.CreatedAt}.Encode(secret)},
+		{"empty", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := DecodeCursor(tt.token, secret); err != ErrInvalidCursor {
+				t.Errorf("got error %v; want %v", err, ErrInvalidCursor)
+			}
+		})
+	}
+}
+
+func TestMemoryListCursor(t *testing.T) {
+	ctx := context.Background()
+	places := NewMemoryPlaceModel(NewMemoryStore(), "reviews")
+
+	// the places created at the same time are ordered by their id, the cursor pages through them without gaps.
+	now := time.Now()
+	for i := 1; i <= 5; i++ {
+		createdAt := now
+		if i == 5 {
+			createdAt = now.Add(time.Minute)
+		}
+		if err := places.InsertOne(ctx, "test", "places", &Place{ID: fmt.Sprintf("place-%d", i), UserID: "owner", CreatedAt: createdAt}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var got []string
+	filter := Filter{Limit: 2}
+	for {
+		page, err := places.List(ctx, "test", "places", filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(*page) == 0 {
+			break
+		}
+		for _, place := range *page {
+			got = append(got, place.ID)
+		}
+		last := (*page)[len(*page)-1]
+		filter.After = &Cursor{CreatedAt: last.CreatedAt, ID: last.ID}
+	}
+	if want := []string{"place-5", "place-4", "place-3", "place-2", "place-1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got places %v; want %v", got, want)
+	}
+}
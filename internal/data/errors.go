@@ -1,5 +1,106 @@
 package data
 
-import "errors"
+import (
+	"context"
+	"errors"
+	"fmt"
 
-var ErrNoDocument = errors.New("no document")
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// The kinds of the errors returned by the models, check the kind of an error with errors.Is, ie.
+// errors.Is(err, data.ErrNoDocument) reports whether the document was not found.
+var (
+	// ErrNoDocument is the kind of the errors of the operations on a document that doesn't exist.
+	ErrNoDocument = errors.New("no document")
+	// ErrConflict is the kind of the errors of the writes conflicting with an existing document, ie. a duplicate key.
+	ErrConflict = errors.New("conflict")
+	// ErrValidation is the kind of the errors of the documents rejected by the database validation rules.
+	ErrValidation = errors.New("validation failed")
+	// ErrUnavailable is the kind of the errors of the operations that failed because the database could not be
+	// reached or didn't respond in time, they may succeed when retried.
+	ErrUnavailable = errors.New("unavailable")
+)
+
+var ErrNotSupported = errors.New("operation not supported")
+
+// IsFailure reports whether the error returned by a model method is a failure of the database operation. A missing
+// document is reported to the client, it is not a failure.
+func IsFailure(err error) bool { return err != nil && !errors.Is(err, ErrNoDocument) }
+
+// Error is the error returned by the models, it carries the kind of the error, the model method that failed and
+// the underlying error.
+type Error struct {
+	// Kind is one of the error kinds, or nil for the unexpected errors.
+	Kind error
+	// Op is the model method, ie. "PlaceModel.FindOne".
+	Op  string
+	Err error
+}
+
+func (e *Error) Error() string {
+	switch {
+	case e.Kind == nil:
+		return fmt.Sprintf("%s: %v", e.Op, e.Err)
+	case e.Err == nil:
+		return fmt.Sprintf("%s: %v", e.Op, e.Kind)
+	default:
+		return fmt.Sprintf("%s: %v: %v", e.Op, e.Kind, e.Err)
+	}
+}
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// Is reports whether the error is of the target kind.
+func (e *Error) Is(target error) bool { return e.Kind != nil && target == e.Kind }
+
+// wrapError returns the error of the model method, with the kind translated from the mongo driver error. A nil
+// error is returned as is.
+func wrapError(op string, err error) error {
+	if err == nil {
+		return nil
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		return err
+	}
+	for _, kind := range kinds {
+		if err == kind {
+			return &Error{Kind: kind, Op: op}
+		}
+	}
+	return &Error{Kind: errorKind(err), Op: op, Err: err}
+}
+
+// kinds are the error kinds, the models return them as is for the errors they detect themselves.
+var kinds = []error{ErrNoDocument, ErrConflict, ErrValidation, ErrUnavailable}
+
+// errorKind returns the kind of the mongo driver error, or nil for an unexpected error. The authorization failures
+// of the database user are unexpected errors, they are a misconfiguration of the server rather than a permission
+// problem of the client.
+func errorKind(err error) error {
+	var coded interface{ HasErrorCode(int) bool }
+	hasCode := func(codes ...int) bool {
+		if !errors.As(err, &coded) {
+			return false
+		}
+		for _, code := range codes {
+			if coded.HasErrorCode(code) {
+				return true
+			}
+		}
+		return false
+	}
+	switch {
+	case errors.Is(err, mongo.ErrNoDocuments):
+		return ErrNoDocument
+	case mongo.IsDuplicateKeyError(err), errors.Is(err, errDuplicateKey):
+		return ErrConflict
+	case hasCode(121):
+		// DocumentValidationFailure
+		return ErrValidation
+	case mongo.IsTimeout(err), mongo.IsNetworkError(err), errors.Is(err, context.Canceled), errors.Is(err, mongo.ErrClientDisconnected):
+		return ErrUnavailable
+	}
+	return nil
+}
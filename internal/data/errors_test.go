@@ -0,0 +1,41 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestWrapError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"kind", ErrConflict, ErrConflict},
+		{"no documents", mongo.ErrNoDocuments, ErrNoDocument},
+		{"duplicate key", mongo.WriteException{WriteErrors: mongo.WriteErrors{{Code: 11000}}}, ErrConflict},
+		{"document validation", mongo.CommandError{Code: 121}, ErrValidation},
+		{"canceled", context.Canceled, ErrUnavailable},
+		// the database user is misconfigured, it is not a permission problem of the client.
+		{"unauthorized", mongo.CommandError{Code: 13, Name: "Unauthorized"}, nil},
+		{"authentication failed", mongo.CommandError{Code: 18, Name: "AuthenticationFailed"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := wrapError("PlaceModel.List", tt.err)
+			var e *Error
+			if !errors.As(err, &e) || e.Op != "PlaceModel.List" {
+				t.Fatalf("got error %v; want a model error", err)
+			}
+			if e.Kind != tt.want {
+				t.Errorf("got kind %v; want %v", e.Kind, tt.want)
+			}
+		})
+	}
+	if err := wrapError("PlaceModel.List", nil); err != nil {
+		t.Errorf("got error %v; want nil", err)
+	}
+}
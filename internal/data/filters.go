@@ -1,6 +1,147 @@
 package data
 
+import (
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// PlaceSortSafelist holds the place fields the listings can be sorted by.
+var PlaceSortSafelist = []string{"created_at", "title", "average_rating", "review_count"}
+
+// Filter holds the pagination, filtering and sorting of the listings. The json tags name the query parameters the
+// filter is read from, so that the validation errors are reported by the parameter name.
 type Filter struct {
-	Skip  int
-	Limit int
+	Skip  int `json:"-"`
+	Limit int `json:"-"`
+	// Categories restricts the results to the places in any of the categories.
+	Categories []string `json:"category"`
+	// City and State restrict the results to the places with the address in the city and state.
+	City  string `json:"city"`
+	State string `json:"state"`
+	// MinRating restricts the results to the places with at least the average rating.
+	MinRating float64 `json:"min_rating"`
+	// CreatedAfter and CreatedBefore restrict the results to the places created within the time range, zero times
+	// leave the range open.
+	CreatedAfter  time.Time `json:"created_after"`
+	CreatedBefore time.Time `json:"created_before"`
+	// UserID restricts the results to the places owned by the user.
+	UserID string `json:"owner"`
+	// Sort holds the fields to sort the results by in order of precedence, a field prefixed with '-' is sorted in
+	// descending order ie. "-created_at". The fields must be in the sort safelist.
+	Sort []string `json:"sort"`
+	// After restricts the results to the documents after the cursor in the default order, it can't be used together
+	// with the sort fields.
+	After *Cursor `json:"cursor"`
+	// Deleted restricts the results to the documents in the trash instead of the live documents.
+	Deleted bool `json:"-"`
+}
+
+// placeQuery translates the filter to the query matching the place documents.
+func (f Filter) placeQuery() bson.D {
+	query := bson.D{}
+	if len(f.Categories) > 0 {
+		query = append(query, bson.E{Key: "categories", Value: bson.D{{Key: "$in", Value: f.Categories}}})
+	}
+	if f.City != "" {
+		query = append(query, bson.E{Key: "location.address.city", Value: f.City})
+	}
+	if f.State != "" {
+		query = append(query, bson.E{Key: "location.address.state", Value: f.State})
+	}
+	if f.MinRating > 0 {
+		query = append(query, bson.E{Key: "average_rating", Value: bson.D{{Key: "$gte", Value: f.MinRating}}})
+	}
+	if !f.CreatedAfter.IsZero() || !f.CreatedBefore.IsZero() {
+		createdAt := bson.D{}
+		if !f.CreatedAfter.IsZero() {
+			createdAt = append(createdAt, bson.E{Key: "$gt", Value: f.CreatedAfter})
+		}
+		if !f.CreatedBefore.IsZero() {
+			createdAt = append(createdAt, bson.E{Key: "$lt", Value: f.CreatedBefore})
+		}
+		query = append(query, bson.E{Key: "created_at", Value: createdAt})
+	}
+	if f.UserID != "" {
+		query = append(query, bson.E{Key: "user_id", Value: f.UserID})
+	}
+	query = append(query, f.deletedQuery())
+	return append(query, f.afterQuery()...)
+}
+
+// reviewQuery translates the filter to the query matching the review documents of the place, or of every place
+// when the place id is empty.
+func (f Filter) reviewQuery(placeID string) bson.D {
+	query := bson.D{}
+	if placeID != "" {
+		query = append(query, bson.E{Key: "place_id", Value: placeID})
+	}
+	query = append(query, f.deletedQuery())
+	return append(query, f.afterQuery()...)
+}
+
+// deletedQuery matches the documents in the trash when the filter is Deleted, otherwise the live documents.
+func (f Filter) deletedQuery() bson.E {
+	return bson.E{Key: "deleted_at", Value: bson.D{{Key: "$exists", Value: f.Deleted}}}
+}
+
+// afterQuery matches the documents after the cursor in the default (created_at, _id) descending order.
+func (f Filter) afterQuery() bson.D {
+	if f.After == nil {
+		return nil
+	}
+	return bson.D{{Key: "$or", Value: bson.A{
+		bson.D{{Key: "created_at", Value: bson.D{{Key: "$lt", Value: f.After.CreatedAt}}}},
+		bson.D{{Key: "created_at", Value: f.After.CreatedAt}, {Key: "_id", Value: bson.D{{Key: "$lt", Value: f.After.ID}}}},
+	}}}
+}
+
+// sort translates the sort fields to the sort document, the newest documents come first when the filter has no
+// sort fields. The _id is always added as the last sort key so that the order of equal documents is stable.
+func (f Filter) sort() bson.D {
+	if len(f.Sort) == 0 {
+		return bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}
+	}
+	sort := bson.D{}
+	for _, field := range f.Sort {
+		if strings.HasPrefix(field, "-") {
+			sort = append(sort, bson.E{Key: strings.TrimPrefix(field, "-"), Value: -1})
+		} else {
+			sort = append(sort, bson.E{Key: field, Value: 1})
+		}
+	}
+	return append(sort, bson.E{Key: "_id", Value: 1})
+}
+
+// GeoQuery restricts places by their location. All coordinates are in the GeoJSON [longitude, latitude] order.
+type GeoQuery struct {
+	// Near is the point the distance of the places is measured from.
+	Near []float64
+	// MaxDistance is the maximum distance in meters from the Near point, zero means no limit.
+	MaxDistance float64
+	// Box restricts the places to the [min longitude, min latitude, max longitude, max latitude] bounding box.
+	Box []float64
+	// Polygon restricts the places to the polygon ring of [longitude, latitude] points.
+	Polygon [][]float64
+}
+
+// within returns the GeoJSON polygon the places must be within, or nil when the query has neither a box nor a
+// polygon. The polygon ring is closed when the last point is not the first one.
+func (q GeoQuery) within() bson.M {
+	var ring [][]float64
+	switch {
+	case len(q.Box) == 4:
+		minLng, minLat, maxLng, maxLat := q.Box[0], q.Box[1], q.Box[2], q.Box[3]
+		ring = [][]float64{{minLng, minLat}, {maxLng, minLat}, {maxLng, maxLat}, {minLng, maxLat}, {minLng, minLat}}
+	case len(q.Polygon) > 0:
+		ring = append(ring, q.Polygon...)
+		first, last := ring[0], ring[len(ring)-1]
+		if first[0] != last[0] || first[1] != last[1] {
+			ring = append(ring, first)
+		}
+	default:
+		return nil
+	}
+	return bson.M{"type": "Polygon", "coordinates": [][][]float64{ring}}
 }
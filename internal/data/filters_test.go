@@ -0,0 +1,90 @@
+package data
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestGeoQueryWithin(t *testing.T) {
+	tests := []struct {
+		name  string
+		query GeoQuery
+		want  [][]float64
+	}{
+		{"near only", GeoQuery{Near: []float64{36.8, -1.3}}, nil},
+		{"box", GeoQuery{Box: []float64{36, -2, 37, -1}}, [][]float64{{36, -2}, {37, -2}, {37, -1}, {36, -1}, {36, -2}}},
+		{"open polygon", GeoQuery{Polygon: [][]float64{{0, 0}, {1, 0}, {1, 1}}}, [][]float64{{0, 0}, {1, 0}, {1, 1}, {0, 0}}},
+		{"closed polygon", GeoQuery{Polygon: [][]float64{{0, 0}, {1, 0}, {1, 1}, {0, 0}}}, [][]float64{{0, 0}, {1, 0}, {1, 1}, {0, 0}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			within := tt.query.within()
+			if tt.want == nil {
+				if within != nil {
+					t.Errorf("got %v; want nil", within)
+				}
+				return
+			}
+			if within["type"] != "Polygon" || !reflect.DeepEqual(within["coordinates"], [][][]float64{tt.want}) {
+				t.Errorf("got %v; want the polygon %v", within, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterPlaceQuery(t *testing.T) {
+	after := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	before := time.Date(2022, 2, 1, 0, 0, 0, 0, time.UTC)
+	live := bson.E{Key: "deleted_at", Value: bson.D{{Key: "$exists", Value: false}}}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   bson.D
+	}{
+		{"empty", Filter{}, bson.D{live}},
+		{"trash", Filter{Deleted: true}, bson.D{{Key: "deleted_at", Value: bson.D{{Key: "$exists", Value: true}}}}},
+		{"fields", Filter{Categories: []string{"cafe", "bar"}, City: "Nairobi", State: "Nairobi County", MinRating: 4, UserID: "user-1"}, bson.D{
+			{Key: "categories", Value: bson.D{{Key: "$in", Value: []string{"cafe", "bar"}}}},
+			{Key: "location.address.city", Value: "Nairobi"},
+			{Key: "location.address.state", Value: "Nairobi County"},
+			{Key: "average_rating", Value: bson.D{{Key: "$gte", Value: 4.0}}},
+			{Key: "user_id", Value: "user-1"},
+			live,
+		}},
+		{"created after", Filter{CreatedAfter: after}, bson.D{{Key: "created_at", Value: bson.D{{Key: "$gt", Value: after}}}, live}},
+		{"created range", Filter{CreatedAfter: after, CreatedBefore: before}, bson.D{
+			{Key: "created_at", Value: bson.D{{Key: "$gt", Value: after}, {Key: "$lt", Value: before}}},
+			live,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.placeQuery(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v; want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterSort(t *testing.T) {
+	tests := []struct {
+		name string
+		sort []string
+		want bson.D
+	}{
+		{"newest first", nil, bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}},
+		{"ascending", []string{"title"}, bson.D{{Key: "title", Value: 1}, {Key: "_id", Value: 1}}},
+		{"fields", []string{"-average_rating", "title"}, bson.D{{Key: "average_rating", Value: -1}, {Key: "title", Value: 1}, {Key: "_id", Value: 1}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := (Filter{Sort: tt.sort}).sort(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v; want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,270 @@
+package data
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"time"
+
+	"firebase.google.com/go/v4/auth"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// JWTOptions holds the keys and the expected claims used to verify locally issued JSON web tokens. At least one
+// of the secret, public key file or JWKS file must be provided, the secret and the public key file are both the key
+// of the tokens without a key id so only one of them can be.
+type JWTOptions struct {
+	// Secret is the shared secret used to verify HS256 signed tokens.
+	Secret string `yaml:"secret" toml:"secret"`
+	// PublicKeyFile is the path to a PEM encoded RSA or ECDSA public key used to verify RS256 and ES256 signed tokens.
+	PublicKeyFile string `yaml:"public_key_file" toml:"public_key_file"`
+	// JWKSFile is the path to a JSON web key set file, keys are selected by the "kid" header of the token.
+	JWKSFile string `yaml:"jwks_file" toml:"jwks_file"`
+	// Issuer and Audience are checked against the "iss" and "aud" claims of the token when not empty.
+	Issuer   string `yaml:"issuer" toml:"issuer"`
+	Audience string `yaml:"audience" toml:"audience"`
+}
+
+// JWTAuthModel is the local implementation of the auth model, it verifies HS256, RS256 and ES256 signed JSON web
+// tokens against the configured keys. It holds no user records, therefore only the token verification is supported.
+type JWTAuthModel struct {
+	// keys maps the key id to the verification key, the key configured without a key id is stored under "".
+	keys     map[string]interface{}
+	issuer   string
+	audience string
+}
+
+func NewJWTAuthModel(opts JWTOptions) (*JWTAuthModel, error) {
+	if opts.Secret != "" && opts.PublicKeyFile != "" {
+		return nil, errors.New("jwt: a secret and a public key file can't both be configured")
+	}
+	model := &JWTAuthModel{keys: map[string]interface{}{}, issuer: opts.Issuer, audience: opts.Audience}
+	if opts.Secret != "" {
+		model.keys[""] = []byte(opts.Secret)
+	}
+	if opts.PublicKeyFile != "" {
+		pem, err := os.ReadFile(opts.PublicKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		key, err := parsePublicKeyPEM(pem)
+		if err != nil {
+			return nil, err
+		}
+		model.keys[""] = key
+	}
+	if opts.JWKSFile != "" {
+		if err := model.loadJWKS(opts.JWKSFile); err != nil {
+			return nil, err
+		}
+	}
+	if len(model.keys) == 0 {
+		return nil, errors.New("jwt: no verification key configured")
+	}
+	return model, nil
+}
+
+// VerifyIDToken verifys a token id, takes context and id token.
+func (m JWTAuthModel) VerifyIDToken(ctx context.Context, idToken string) (*auth.Token, error) {
+	claims := jwt.MapClaims{}
+	parser := jwt.NewParser(jwt.WithValidMethods([]string{"HS256", "RS256", "ES256"}))
+	if _, err := parser.ParseWithClaims(idToken, claims, m.keyFunc); err != nil {
+		return nil, err
+	}
+	// the parser only checks the expiry of the tokens which have one, a token without expiry would never expire.
+	if !claims.VerifyExpiresAt(time.Now().Unix(), true) {
+		return nil, errors.New("jwt: missing expiry")
+	}
+	if m.issuer != "" && !claims.VerifyIssuer(m.issuer, true) {
+		return nil, errors.New("jwt: invalid issuer")
+	}
+	if m.audience != "" && !claims.VerifyAudience(m.audience, true) {
+		return nil, errors.New("jwt: invalid audience")
+	}
+	subject, _ := claims["sub"].(string)
+	if subject == "" {
+		return nil, errors.New("jwt: missing subject")
+	}
+	token := &auth.Token{
+		Issuer:  m.issuer,
+		Subject: subject,
+		UID:     subject,
+		Claims:  claims,
+	}
+	if issuer, ok := claims["iss"].(string); ok {
+		token.Issuer = issuer
+	}
+	if audience, ok := claims["aud"].(string); ok {
+		token.Audience = audience
+	}
+	if exp, ok := claims["exp"].(float64); ok {
+		token.Expires = int64(exp)
+	}
+	if iat, ok := claims["iat"].(float64); ok {
+		token.IssuedAt = int64(iat)
+	}
+	return token, nil
+}
+
+// keyFunc selects the verification key by the "kid" header of the token and checks that the key type matches the
+// signing method, so that a public key can never be used as an HMAC secret.
+func (m JWTAuthModel) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := m.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("jwt: unknown key id %q", kid)
+	}
+	switch token.Method.(type) {
+	case *jwt.SigningMethodHMAC:
+		if _, ok := key.([]byte); ok {
+			return key, nil
+		}
+	case *jwt.SigningMethodRSA:
+		if _, ok := key.(*rsa.PublicKey); ok {
+			return key, nil
+		}
+	case *jwt.SigningMethodECDSA:
+		if _, ok := key.(*ecdsa.PublicKey); ok {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("jwt: key type does not match signing method %s", token.Method.Alg())
+}
+
+// Check checks that the auth provider is configured to verify the id tokens, takes a context.
+func (m JWTAuthModel) Check(ctx context.Context) error {
+	if len(m.keys) == 0 {
+		return errors.New("jwt: no verification key configured")
+	}
+	return nil
+}
+
+// RevokeRefreshTokens revokes refresh token associated by a user account, takes context and user uid.
+func (JWTAuthModel) RevokeRefreshTokens(ctx context.Context, uid string) error {
+	return ErrNotSupported
+}
+
+// GetUser gets user by id, takes context and user uid.
+func (JWTAuthModel) GetUser(ctx context.Context, uid string) (*auth.UserRecord, error) {
+	return nil, ErrNotSupported
+}
+
+// GetUserByEmail gets user by email, takes context and email.
+func (JWTAuthModel) GetUserByEmail(ctx context.Context, email string) (*auth.UserRecord, error) {
+	return nil, ErrNotSupported
+}
+
+// GetUserByPhone gets user by phone, takes context and phone.
+func (JWTAuthModel) GetUserByPhone(ctx context.Context, phone string) (*auth.UserRecord, error) {
+	return nil, ErrNotSupported
+}
+
+// CreateUser creates a new user, takes a context and user object.
+func (JWTAuthModel) CreateUser(ctx context.Context, user *User) (*auth.UserRecord, error) {
+	return nil, ErrNotSupported
+}
+
+// UpdateUser updates an existing user, takes context and user object.
+func (JWTAuthModel) UpdateUser(ctx context.Context, user *User) (*auth.UserRecord, error) {
+	return nil, ErrNotSupported
+}
+
+// DeleteUser delete a user by id, takes a context and user uid.
+func (JWTAuthModel) DeleteUser(ctx context.Context, uid string) error {
+	return ErrNotSupported
+}
+
+// CustomClaimsSet sets custom claims to a user, takes context, uid and claims map.
+func (JWTAuthModel) CustomClaimsSet(ctx context.Context, uid string, claims map[string]interface{}) error {
+	return ErrNotSupported
+}
+
+// parsePublicKeyPEM parses a PEM encoded RSA or ECDSA public key.
+func parsePublicKeyPEM(pem []byte) (interface{}, error) {
+	if key, err := jwt.ParseRSAPublicKeyFromPEM(pem); err == nil {
+		return key, nil
+	}
+	if key, err := jwt.ParseECPublicKeyFromPEM(pem); err == nil {
+		return key, nil
+	}
+	return nil, errors.New("jwt: public key must be a PEM encoded RSA or ECDSA key")
+}
+
+// jsonWebKey is a single key of a JSON web key set as defined by RFC 7517, only the fields needed to build the
+// verification keys are decoded.
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	K   string `json:"k"`
+}
+
+// loadJWKS reads a JSON web key set file and adds its signature keys to the model keys.
+func (m *JWTAuthModel) loadJWKS(path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(b, &set); err != nil {
+		return fmt.Errorf("jwt: invalid jwks file: %w", err)
+	}
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.key()
+		if err != nil {
+			return fmt.Errorf("jwt: invalid jwks key %q: %w", jwk.Kid, err)
+		}
+		m.keys[jwk.Kid] = key
+	}
+	return nil
+}
+
+// key builds the verification key described by the JSON web key.
+func (jwk jsonWebKey) key() (interface{}, error) {
+	decode := base64.RawURLEncoding.DecodeString
+	switch jwk.Kty {
+	case "oct":
+		return decode(jwk.K)
+	case "RSA":
+		n, err := decode(jwk.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode(jwk.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		if jwk.Crv != "P-256" {
+			return nil, fmt.Errorf("unsupported curve %q", jwk.Crv)
+		}
+		x, err := decode(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decode(jwk.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", jwk.Kty)
+}
@@ -0,0 +1,159 @@
+package data
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+func TestJWTAuthModel(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKeyPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	publicKeyFile := filepath.Join(dir, "public.pem")
+	if err := os.WriteFile(publicKeyFile, publicKeyPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	encode := base64.RawURLEncoding.EncodeToString
+	jwks, err := json.Marshal(map[string]interface{}{"keys": []map[string]string{
+		{"kty": "RSA", "kid": "rsa-1", "use": "sig", "n": encode(rsaKey.N.Bytes()), "e": encode(big.NewInt(int64(rsaKey.E)).Bytes())},
+		{"kty": "EC", "kid": "ec-1", "crv": "P-256", "x": encode(ecKey.X.Bytes()), "y": encode(ecKey.Y.Bytes())},
+		{"kty": "oct", "kid": "hs-1", "k": encode([]byte("jwks-secret"))},
+		{"kty": "RSA", "kid": "enc-1", "use": "enc", "n": encode(rsaKey.N.Bytes()), "e": encode(big.NewInt(int64(rsaKey.E)).Bytes())},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwksFile := filepath.Join(dir, "jwks.json")
+	if err := os.WriteFile(jwksFile, jwks, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	claims := func(extra jwt.MapClaims) jwt.MapClaims {
+		c := jwt.MapClaims{"sub": "user-1", "iss": "trouver", "aud": "api", "exp": time.Now().Add(time.Hour).Unix()}
+		for k, v := range extra {
+			if v == nil {
+				delete(c, k)
+				continue
+			}
+			c[k] = v
+		}
+		return c
+	}
+	sign := func(method jwt.SigningMethod, kid string, key interface{}, c jwt.MapClaims) string {
+		token := jwt.NewWithClaims(method, c)
+		if kid != "" {
+			token.Header["kid"] = kid
+		}
+		s, err := token.SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+
+	secret, err := NewJWTAuthModel(JWTOptions{Secret: "secret", Issuer: "trouver", Audience: "api"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKey, err := NewJWTAuthModel(JWTOptions{PublicKeyFile: publicKeyFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	keySet, err := NewJWTAuthModel(JWTOptions{JWKSFile: jwksFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		model *JWTAuthModel
+		token string
+		ok    bool
+	}{
+		{"hs256 secret", secret, sign(jwt.SigningMethodHS256, "", []byte("secret"), claims(nil)), true},
+		{"hs256 wrong secret", secret, sign(jwt.SigningMethodHS256, "", []byte("other"), claims(nil)), false},
+		{"rs256 public key file", publicKey, sign(jwt.SigningMethodRS256, "", rsaKey, claims(nil)), true},
+		{"hs256 signed with the public key", publicKey, sign(jwt.SigningMethodHS256, "", publicKeyPEM, claims(nil)), false},
+		{"none algorithm", publicKey, sign(jwt.SigningMethodNone, "", jwt.UnsafeAllowNoneSignatureType, claims(nil)), false},
+		{"jwks rs256", keySet, sign(jwt.SigningMethodRS256, "rsa-1", rsaKey, claims(nil)), true},
+		{"jwks es256", keySet, sign(jwt.SigningMethodES256, "ec-1", ecKey, claims(nil)), true},
+		{"jwks hs256", keySet, sign(jwt.SigningMethodHS256, "hs-1", []byte("jwks-secret"), claims(nil)), true},
+		{"jwks key of another type", keySet, sign(jwt.SigningMethodES256, "rsa-1", ecKey, claims(nil)), false},
+		{"jwks unknown key id", keySet, sign(jwt.SigningMethodRS256, "rsa-2", rsaKey, claims(nil)), false},
+		{"jwks encryption key", keySet, sign(jwt.SigningMethodRS256, "enc-1", rsaKey, claims(nil)), false},
+		{"jwks missing key id", keySet, sign(jwt.SigningMethodRS256, "", rsaKey, claims(nil)), false},
+		{"wrong issuer", secret, sign(jwt.SigningMethodHS256, "", []byte("secret"), claims(jwt.MapClaims{"iss": "other"})), false},
+		{"wrong audience", secret, sign(jwt.SigningMethodHS256, "", []byte("secret"), claims(jwt.MapClaims{"aud": "other"})), false},
+		{"expired", secret, sign(jwt.SigningMethodHS256, "", []byte("secret"), claims(jwt.MapClaims{"exp": time.Now().Add(-time.Hour).Unix()})), false},
+		{"missing expiry", secret, sign(jwt.SigningMethodHS256, "", []byte("secret"), claims(jwt.MapClaims{"exp": nil})), false},
+		{"missing subject", secret, sign(jwt.SigningMethodHS256, "", []byte("secret"), claims(jwt.MapClaims{"sub": nil})), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := tt.model.VerifyIDToken(ctx, tt.token)
+			if !tt.ok {
+				if err == nil {
+					t.Fatal("got no error; want one")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("got error %v; want none", err)
+			}
+			if token.UID != "user-1" || token.Subject != "user-1" {
+				t.Errorf("got uid %q and subject %q; want %q", token.UID, token.Subject, "user-1")
+			}
+			if token.Claims["sub"] != "user-1" {
+				t.Errorf("got claims %v; want the token claims", token.Claims)
+			}
+		})
+	}
+}
+
+func TestNewJWTAuthModel(t *testing.T) {
+	if _, err := NewJWTAuthModel(JWTOptions{}); err == nil {
+		t.Error("no key: got no error; want one")
+	}
+	if _, err := NewJWTAuthModel(JWTOptions{Secret: "secret", PublicKeyFile: "public.pem"}); err == nil {
+		t.Error("secret and public key: got no error; want one")
+	}
+	if _, err := NewJWTAuthModel(JWTOptions{PublicKeyFile: filepath.Join(t.TempDir(), "missing.pem")}); err == nil {
+		t.Error("missing public key file: got no error; want one")
+	}
+
+	invalid := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(invalid, []byte(`{"keys": [{"kty": "EC", "kid": "ec-1", "crv": "P-521"}]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewJWTAuthModel(JWTOptions{JWKSFile: invalid}); err == nil {
+		t.Error("unsupported curve: got no error; want one")
+	}
+}
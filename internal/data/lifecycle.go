@@ -0,0 +1,135 @@
+package data
+
+import (
+	"context"
+	"sort"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// orphanDeletedBy is the deleted_by of the orphaned reviews moved to the trash by the consistency check when their
+// place no longer exists.
+const orphanDeletedBy = "consistency-check"
+
+// liveReviewsFilter returns the query of the reviews of the place that are not in the trash.
+func liveReviewsFilter(placeID string) bson.D {
+	return bson.D{{Key: "place_id", Value: placeID}, {Key: "deleted_at", Value: bson.D{{Key: "$exists", Value: false}}}}
+}
+
+// cascadedReviewsFilter returns the query of the reviews moved to the trash with the place, they share the deleted_at
+// of the place tombstone. The reviews deleted before the place are not matched.
+func cascadedReviewsFilter(placeID string, tombstone Tombstone) bson.D {
+	return bson.D{{Key: "place_id", Value: placeID}, {Key: "deleted_at", Value: tombstone.DeletedAt}}
+}
+
+// livePlace reports whether the place exists and is not in the trash, the reviews reference the places by id.
+func (r ReviewModel) livePlace(ctx context.Context, database string, placeID string) (bool, error) {
+	coll := r.client.Database(database).Collection(r.placeCollection)
+	count, err := coll.CountDocuments(ctx, liveFilter(placeID))
+	return count > 0, err
+}
+
+// Orphans finds the orphaned reviews in the reviews collection, the reviews not in the trash whose place doesn't exist
+// or is in the trash, takes a context, database name, collection name and whether to repair them. The orphans are
+// moved to the trash when repaired, with the tombstone of their place when it is in the trash so that they are
+// restored with it.
+func (r ReviewModel) Orphans(ctx context.Context, database, collection string, repair bool) (_ *Reviews, err error) {
+	defer func() { err = wrapError("ReviewModel.Orphans", err) }()
+	coll := r.client.Database(database).Collection(collection)
+	// the place of each review is joined, the orphans have no place or a place with a tombstone.
+	cursor, err := coll.Aggregate(ctx, bson.A{
+		bson.D{{Key: "$match", Value: bson.D{{Key: "deleted_at", Value: bson.D{{Key: "$exists", Value: false}}}}}},
+		bson.D{{Key: "$lookup", Value: bson.D{
+			{Key: "from", Value: r.placeCollection},
+			{Key: "localField", Value: "place_id"},
+			{Key: "foreignField", Value: "_id"},
+			{Key: "as", Value: "place"},
+		}}},
+		bson.D{{Key: "$match", Value: bson.D{{Key: "$or", Value: bson.A{
+			bson.D{{Key: "place", Value: bson.D{{Key: "$size", Value: 0}}}},
+			bson.D{{Key: "place.deleted_at", Value: bson.D{{Key: "$exists", Value: true}}}},
+		}}}}},
+		bson.D{{Key: "$sort", Value: bson.D{{Key: "_id", Value: 1}}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var orphans []struct {
+		Review `bson:",inline"`
+		Place  []Place `bson:"place"`
+	}
+	if err := cursor.All(ctx, &orphans); err != nil {
+		return nil, err
+	}
+
+	reviews := make(Reviews, 0, len(orphans))
+	for _, orphan := range orphans {
+		reviews = append(reviews, orphan.Review)
+		if !repair {
+			continue
+		}
+		tombstone := newTombstone(orphanDeletedBy)
+		if len(orphan.Place) > 0 {
+			tombstone = orphan.Place[0].Tombstone
+		}
+		if _, err := coll.UpdateOne(ctx, liveFilter(orphan.ID), tombstoneUpdate(tombstone)); err != nil {
+			return nil, err
+		}
+	}
+	return &reviews, nil
+}
+
+// Duplicates finds the duplicated reviews in the reviews collection, the reviews of a user on a place but the one kept,
+// takes a context, database name, collection name and whether to delete the duplicates. The review kept is the newest
+// review not in the trash, or the newest review when they are all in the trash. The duplicates are deleted for good
+// with their revisions when repaired, so that the unique index of the reviews can be created.
+func (r ReviewModel) Duplicates(ctx context.Context, database, collection string, repair bool) (_ *Reviews, err error) {
+	defer func() { err = wrapError("ReviewModel.Duplicates", err) }()
+	coll := r.client.Database(database).Collection(collection)
+	// the reviews missing a deleted_at sort first, the first review of each group is kept.
+	cursor, err := coll.Aggregate(ctx, bson.A{
+		bson.D{{Key: "$sort", Value: bson.D{{Key: "deleted_at", Value: 1}, {Key: "created_at", Value: -1}, {Key: "_id", Value: 1}}}},
+		bson.D{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: bson.D{{Key: "place_id", Value: "$place_id"}, {Key: "user_id", Value: "$user_id"}}},
+			{Key: "reviews", Value: bson.D{{Key: "$push", Value: "$$ROOT"}}},
+			{Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}},
+		}}},
+		bson.D{{Key: "$match", Value: bson.D{{Key: "count", Value: bson.D{{Key: "$gt", Value: 1}}}}}},
+	}, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return nil, err
+	}
+	var groups []struct {
+		Reviews Reviews `bson:"reviews"`
+	}
+	if err := cursor.All(ctx, &groups); err != nil {
+		return nil, err
+	}
+
+	duplicates := Reviews{}
+	for _, group := range groups {
+		duplicates = append(duplicates, group.Reviews[1:]...)
+	}
+	sort.Slice(duplicates, func(i, j int) bool { return duplicates[i].ID < duplicates[j].ID })
+	if !repair || len(duplicates) == 0 {
+		return &duplicates, nil
+	}
+
+	ids := make(bson.A, 0, len(duplicates))
+	places := map[string]bool{}
+	for _, review := range duplicates {
+		ids = append(ids, review.ID)
+		places[review.PlaceID] = true
+	}
+	if _, err := r.purgeReviews(ctx, database, collection, bson.D{{Key: "_id", Value: bson.D{{Key: "$in", Value: ids}}}}); err != nil {
+		return nil, err
+	}
+	// the deleted duplicates not in the trash were counted in the rating of their place.
+	for placeID := range places {
+		if err := r.updateRatingStats(ctx, database, collection, placeID); err != nil {
+			return nil, err
+		}
+	}
+	return &duplicates, nil
+}
@@ -0,0 +1,896 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+var errDuplicateKey = errors.New("duplicate key")
+
+// MemoryStore is a concurrency safe in-memory store of the place and review documents, used for the tests and the
+// local development without a database. Documents are stored per database and collection name, and they are copied
+// through their bson encoding on every read and write, so that they behave as the documents stored in MongoDB. Like
+// the mongo driver, the operations fail with the context error once the context is done.
+type MemoryStore struct {
+	mu        sync.RWMutex
+	places    map[string]map[string]Place
+	reviews   map[string]map[string]Review
+	revisions map[string]map[string]Revision
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		places:    map[string]map[string]Place{},
+		reviews:   map[string]map[string]Review{},
+		revisions: map[string]map[string]Revision{},
+	}
+}
+
+// placeCollection returns the places of the collection. The collection is created when it doesn't exist and create
+// is set, which requires the write lock, otherwise a nil map is returned for the missing collection.
+func (s *MemoryStore) placeCollection(database, collection string, create bool) map[string]Place {
+	key := database + "." + collection
+	if s.places[key] == nil && create {
+		s.places[key] = map[string]Place{}
+	}
+	return s.places[key]
+}
+
+// reviewCollection returns the reviews of the collection. The collection is created when it doesn't exist and create
+// is set, which requires the write lock, otherwise a nil map is returned for the missing collection.
+func (s *MemoryStore) reviewCollection(database, collection string, create bool) map[string]Review {
+	key := database + "." + collection
+	if s.reviews[key] == nil && create {
+		s.reviews[key] = map[string]Review{}
+	}
+	return s.reviews[key]
+}
+
+// revisionCollection returns the revisions of the collection. The collection is created when it doesn't exist and
+// create is set, which requires the write lock, otherwise a nil map is returned for the missing collection.
+func (s *MemoryStore) revisionCollection(database, collection string, create bool) map[string]Revision {
+	key := database + "." + collection
+	if s.revisions[key] == nil && create {
+		s.revisions[key] = map[string]Revision{}
+	}
+	return s.revisions[key]
+}
+
+// MemoryPlaceModel is the in-memory implementation of the place model.
+type MemoryPlaceModel struct {
+	store *MemoryStore
+	// reviewCollection is the name of the reviews collection the reviews of the places are moved to the trash and
+	// restored in with their place.
+	reviewCollection string
+}
+
+func NewMemoryPlaceModel(store *MemoryStore, reviewCollection string) *MemoryPlaceModel {
+	return &MemoryPlaceModel{store: store, reviewCollection: reviewCollection}
+}
+
+// InsertOne inserts a new document to the places collection, takes a context, database name, collection name
+// and pointer to place struct object with the data to be inserted.
+func (m MemoryPlaceModel) InsertOne(ctx context.Context, database, collection string, place *Place) (err error) {
+	defer func() { err = wrapError("PlaceModel.InsertOne", err) }()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+	places := m.store.placeCollection(database, collection, true)
+	if _, ok := places[place.ID]; ok {
+		return errDuplicateKey
+	}
+	place.resetMaintained()
+	var stored Place
+	if err := copyDocument(place, &stored); err != nil {
+		return err
+	}
+	places[place.ID] = stored
+	return nil
+}
+
+// UpdateOne updated a specific place document in the places collection, takes a context, database name, collection name
+// and pointer to place struct objet with data to be updated. The fields left empty are removed from the document.
+func (m MemoryPlaceModel) UpdateOne(ctx context.Context, database, collection string, place *Place) (err error) {
+	defer func() { err = wrapError("PlaceModel.UpdateOne", err) }()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+	places := m.store.placeCollection(database, collection, true)
+	existing, ok := places[place.ID]
+	if !ok || existing.Deleted() {
+		return ErrNoDocument
+	}
+	if existing.Version != place.Version {
+		return ErrConflict
+	}
+	place.Version++
+	update, err := updateDocument(place, placeProtectedFields)
+	if err != nil {
+		return err
+	}
+	var updated Place
+	if err := applyUpdate(existing, update, &updated); err != nil {
+		return err
+	}
+	places[place.ID] = updated
+	return nil
+}
+
+// FindOne finds a specific places document in the places collection, takes a context, database name, collection name
+// and the document id
+func (m MemoryPlaceModel) FindOne(ctx context.Context, database, collection string, placeID string) (_ *Place, err error) {
+	defer func() { err = wrapError("PlaceModel.FindOne", err) }()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.store.mu.RLock()
+	defer m.store.mu.RUnlock()
+	stored, ok := m.store.placeCollection(database, collection, false)[placeID]
+	if !ok || stored.Deleted() {
+		return nil, ErrNoDocument
+	}
+	var place Place
+	if err := copyDocument(stored, &place); err != nil {
+		return nil, err
+	}
+	return &place, nil
+}
+
+// List finds all places documents in the places collections, takes a context, database name, collection name
+// and filter.
+func (m MemoryPlaceModel) List(ctx context.Context, database, collection string, filter Filter) (_ *Places, err error) {
+	defer func() { err = wrapError("PlaceModel.List", err) }()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	places, err := m.find(database, collection, filter.matchPlace)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(places, func(i, j int) bool { return filter.lessPlace(&places[i], &places[j]) })
+	return paginate(places, filter), nil
+}
+
+// Count counts the places documents in the places collection, takes a context, database name, collection name
+// and filter.
+func (m MemoryPlaceModel) Count(ctx context.Context, database, collection string, filter Filter) (_ int64, err error) {
+	defer func() { err = wrapError("PlaceModel.Count", err) }()
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	places, err := m.find(database, collection, filter.matchPlace)
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(places)), nil
+}
+
+// DeleteOne deletes a specific place document in the places collection, takes a context, database name, collection name
+// document id and version and the id of the user deleting it. The place is moved to the trash with its reviews.
+func (m MemoryPlaceModel) DeleteOne(ctx context.Context, database, collection string, placeID string, version int64, deletedBy string) (err error) {
+	defer func() { err = wrapError("PlaceModel.DeleteOne", err) }()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+	places := m.store.placeCollection(database, collection, true)
+	place, ok := places[placeID]
+	if !ok || place.Deleted() {
+		return ErrNoDocument
+	}
+	if place.Version != version {
+		return ErrConflict
+	}
+	place.Tombstone = newTombstone(deletedBy)
+	place.Version++
+	// the document is stored by its own id, assigning an existing key replaces it and the id argument may be backed
+	// by the buffer of a request.
+	places[place.ID] = place
+	reviews := m.store.reviewCollection(database, m.reviewCollection, true)
+	for _, review := range reviews {
+		if review.PlaceID == place.ID && !review.Deleted() {
+			review.Tombstone = place.Tombstone
+			review.Version++
+			reviews[review.ID] = review
+		}
+	}
+	return nil
+}
+
+// Restore restores a specific place document from the trash, takes a context, database name, collection name and
+// document id. The reviews moved to the trash with the place are restored with it.
+func (m MemoryPlaceModel) Restore(ctx context.Context, database, collection string, placeID string) (err error) {
+	defer func() { err = wrapError("PlaceModel.Restore", err) }()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+	places := m.store.placeCollection(database, collection, true)
+	place, ok := places[placeID]
+	if !ok || !place.Deleted() {
+		return ErrNoDocument
+	}
+	reviews := m.store.reviewCollection(database, m.reviewCollection, true)
+	for _, review := range reviews {
+		if review.PlaceID == place.ID && review.Deleted() && review.DeletedAt.Equal(*place.DeletedAt) {
+			review.Tombstone = Tombstone{}
+			review.Version++
+			reviews[review.ID] = review
+		}
+	}
+	place.Tombstone = Tombstone{}
+	place.Version++
+	places[place.ID] = place
+	return nil
+}
+
+// Purge deletes the place documents moved to the trash before the given time for good, takes a context, database
+// name, collection name and the time.
+func (m MemoryPlaceModel) Purge(ctx context.Context, database, collection string, before time.Time) (_ int64, err error) {
+	defer func() { err = wrapError("PlaceModel.Purge", err) }()
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+	places := m.store.placeCollection(database, collection, true)
+	var count int64
+	for id, place := range places {
+		if place.Deleted() && place.DeletedAt.Before(before) {
+			delete(places, id)
+			count++
+		}
+	}
+	return count, nil
+}
+
+// SearchPlace searches place documents in places collection by search term, takes a context, database name, collection name
+// search term and filter. The relevance score approximates the MongoDB text score with the weights of the text
+// index, a place matches when any of the term words is in its title, categories or description.
+func (m MemoryPlaceModel) SearchPlace(ctx context.Context, database, collection string, term string, filter Filter) (_ *Places, err error) {
+	defer func() { err = wrapError("PlaceModel.SearchPlace", err) }()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	words := textWords(term)
+	places, err := m.find(database, collection, func(place *Place) bool {
+		place.Score = textScore(place, words)
+		return place.Score > 0 && filter.matchPlace(place)
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(places, func(i, j int) bool { return places[i].Score > places[j].Score })
+	return paginate(places, filter), nil
+}
+
+// Nearby finds place documents in places collection by location, takes a context, database name, collection name
+// geo query and filter. Distances are computed on a sphere with the radius MongoDB uses for spherical queries.
+func (m MemoryPlaceModel) Nearby(ctx context.Context, database, collection string, query GeoQuery, filter Filter) (_ *Places, err error) {
+	defer func() { err = wrapError("PlaceModel.Nearby", err) }()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var ring [][]float64
+	if within := query.within(); within != nil {
+		ring = within["coordinates"].([][][]float64)[0]
+	}
+	places, err := m.find(database, collection, func(place *Place) bool {
+		point := place.Location.Geo.Coordinates
+		if place.Location.Geo.Type != "Point" || len(point) != 2 {
+			return false
+		}
+		if ring != nil && !inRing(point, ring) {
+			return false
+		}
+		if query.Near != nil {
+			place.Distance = sphereDistance(query.Near, point)
+			if query.MaxDistance > 0 && place.Distance > query.MaxDistance {
+				return false
+			}
+		}
+		return filter.matchPlace(place)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if query.Near != nil {
+		sort.SliceStable(places, func(i, j int) bool { return places[i].Distance < places[j].Distance })
+	} else {
+		sort.SliceStable(places, func(i, j int) bool { return filter.lessPlace(&places[i], &places[j]) })
+	}
+	return paginate(places, filter), nil
+}
+
+// find returns copies of the places of the collection matching the predicate, the predicate may set the computed
+// fields of the place copy.
+func (m MemoryPlaceModel) find(database, collection string, match func(place *Place) bool) (Places, error) {
+	m.store.mu.RLock()
+	defer m.store.mu.RUnlock()
+	var places Places
+	for _, stored := range m.store.placeCollection(database, collection, false) {
+		var place Place
+		if err := copyDocument(stored, &place); err != nil {
+			return nil, err
+		}
+		if match(&place) {
+			places = append(places, place)
+		}
+	}
+	return places, nil
+}
+
+// MemoryReviewModel is the in-memory implementation of the review model.
+type MemoryReviewModel struct {
+	store *MemoryStore
+	// placeCollection is the name of the places collection the rating statistics of the reviewed places are set in.
+	placeCollection string
+	// revisionCollection is the name of the collection the previous versions of the updated reviews are saved in.
+	revisionCollection string
+}
+
+func NewMemoryReviewModel(store *MemoryStore, placeCollection, revisionCollection string) *MemoryReviewModel {
+	return &MemoryReviewModel{store: store, placeCollection: placeCollection, revisionCollection: revisionCollection}
+}
+
+// InsertOne inserts a new document to the reviews collection, takes a context, database name, collection name
+// and pointer to place struct object with the data to be inserted.
+func (m MemoryReviewModel) InsertOne(ctx context.Context, database, collection string, review *Review) (err error) {
+	defer func() { err = wrapError("ReviewModel.InsertOne", err) }()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+	if !m.livePlace(database, review.PlaceID) {
+		return ErrValidation
+	}
+	reviews := m.store.reviewCollection(database, collection, true)
+	if _, ok := reviews[review.ID]; ok {
+		return errDuplicateKey
+	}
+	// emulate the unique index of the place and user of the reviews, the reviews in the trash count.
+	for _, stored := range reviews {
+		if stored.PlaceID == review.PlaceID && stored.UserID == review.UserID {
+			return errDuplicateKey
+		}
+	}
+	review.resetMaintained()
+	var stored Review
+	if err := copyDocument(review, &stored); err != nil {
+		return err
+	}
+	reviews[review.ID] = stored
+	m.updateRatingStats(database, collection, review.PlaceID)
+	return nil
+}
+
+// UpdateOne updates a specific review document in the reviews collection, takes a context, database name, collection name
+// and pointer to review struct objet with data to be updated. The fields left empty are removed from the document.
+func (m MemoryReviewModel) UpdateOne(ctx context.Context, database, collection string, review *Review) (err error) {
+	defer func() { err = wrapError("ReviewModel.UpdateOne", err) }()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+	reviews := m.store.reviewCollection(database, collection, true)
+	existing, ok := reviews[review.ID]
+	if !ok || existing.Deleted() {
+		return ErrNoDocument
+	}
+	if existing.Version != review.Version {
+		return ErrConflict
+	}
+	review.Version++
+	update, err := updateDocument(review, reviewProtectedFields)
+	if err != nil {
+		return err
+	}
+	var updated Review
+	if err := applyUpdate(existing, update, &updated); err != nil {
+		return err
+	}
+	reviews[review.ID] = updated
+	revision := newRevision(&existing, time.Now())
+	m.store.revisionCollection(database, m.revisionCollection, true)[revision.ID] = revision
+	m.updateRatingStats(database, collection, existing.PlaceID)
+	return nil
+}
+
+// FindOne finds a specific review document in the reviews collection, takes a context, database name, collection name
+// and the document id
+func (m MemoryReviewModel) FindOne(ctx context.Context, database, collection string, reviewID string) (_ *Review, err error) {
+	defer func() { err = wrapError("ReviewModel.FindOne", err) }()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.store.mu.RLock()
+	defer m.store.mu.RUnlock()
+	stored, ok := m.store.reviewCollection(database, collection, false)[reviewID]
+	if !ok || stored.Deleted() {
+		return nil, ErrNoDocument
+	}
+	var review Review
+	if err := copyDocument(stored, &review); err != nil {
+		return nil, err
+	}
+	return &review, nil
+}
+
+// List finds all reviews documents in the reviews collections by place id, takes a context, database name
+// collection name and filter.
+func (m MemoryReviewModel) List(ctx context.Context, database, collection string, placeID string, filter Filter) (_ *Reviews, err error) {
+	defer func() { err = wrapError("ReviewModel.List", err) }()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	reviews, err := m.find(database, collection, placeID, filter)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(reviews, func(i, j int) bool {
+		return newerFirst(reviews[i].CreatedAt.UnixMilli(), reviews[i].ID, reviews[j].CreatedAt.UnixMilli(), reviews[j].ID)
+	})
+	return paginate(reviews, filter), nil
+}
+
+// Count counts the reviews documents in the reviews collection by place id, takes a context, database name
+// collection name and filter.
+func (m MemoryReviewModel) Count(ctx context.Context, database, collection string, placeID string, filter Filter) (_ int64, err error) {
+	defer func() { err = wrapError("ReviewModel.Count", err) }()
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	reviews, err := m.find(database, collection, placeID, filter)
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(reviews)), nil
+}
+
+// DeleteOne deletes a specific review document in the reviews collection, takes a context, database name, collection name
+// document id and version and the id of the user deleting it. The review is moved to the trash.
+func (m MemoryReviewModel) DeleteOne(ctx context.Context, database, collection string, reviewID string, version int64, deletedBy string) (err error) {
+	defer func() { err = wrapError("ReviewModel.DeleteOne", err) }()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+	reviews := m.store.reviewCollection(database, collection, true)
+	review, ok := reviews[reviewID]
+	if !ok || review.Deleted() {
+		return ErrNoDocument
+	}
+	if review.Version != version {
+		return ErrConflict
+	}
+	review.Tombstone = newTombstone(deletedBy)
+	review.Version++
+	reviews[review.ID] = review
+	m.updateRatingStats(database, collection, review.PlaceID)
+	return nil
+}
+
+// Restore restores a specific review document from the trash, takes a context, database name, collection name and
+// document id. ErrConflict is returned when the place of the review is in the trash.
+func (m MemoryReviewModel) Restore(ctx context.Context, database, collection string, reviewID string) (err error) {
+	defer func() { err = wrapError("ReviewModel.Restore", err) }()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+	reviews := m.store.reviewCollection(database, collection, true)
+	review, ok := reviews[reviewID]
+	if !ok || !review.Deleted() {
+		return ErrNoDocument
+	}
+	if !m.livePlace(database, review.PlaceID) {
+		return ErrConflict
+	}
+	review.Tombstone = Tombstone{}
+	review.Version++
+	reviews[review.ID] = review
+	m.updateRatingStats(database, collection, review.PlaceID)
+	return nil
+}
+
+// Purge deletes the review documents moved to the trash before the given time for good with their revisions, takes a
+// context, database name, collection name and the time.
+func (m MemoryReviewModel) Purge(ctx context.Context, database, collection string, before time.Time) (_ int64, err error) {
+	defer func() { err = wrapError("ReviewModel.Purge", err) }()
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+	var count int64
+	for id, review := range m.store.reviewCollection(database, collection, true) {
+		if review.Deleted() && review.DeletedAt.Before(before) {
+			m.purgeReview(database, collection, id)
+			count++
+		}
+	}
+	return count, nil
+}
+
+// SetReply sets the reply of a specific review document in the reviews collection, takes a context, database name,
+// collection name, the review id and version and the reply, a nil reply deletes the reply.
+func (m MemoryReviewModel) SetReply(ctx context.Context, database, collection string, reviewID string, version int64, reply *Reply) (err error) {
+	defer func() { err = wrapError("ReviewModel.SetReply", err) }()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+	reviews := m.store.reviewCollection(database, collection, true)
+	review, ok := reviews[reviewID]
+	if !ok || review.Deleted() {
+		return ErrNoDocument
+	}
+	if review.Version != version {
+		return ErrConflict
+	}
+	review.Reply = nil
+	if reply != nil {
+		stored := *reply
+		review.Reply = &stored
+	}
+	review.Version++
+	reviews[review.ID] = review
+	return nil
+}
+
+// History finds the revisions of a specific review, takes a context, database name, reviews collection name and the
+// review id. The revisions are sorted from the oldest.
+func (m MemoryReviewModel) History(ctx context.Context, database, collection string, reviewID string) (_ *Revisions, err error) {
+	defer func() { err = wrapError("ReviewModel.History", err) }()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.store.mu.RLock()
+	defer m.store.mu.RUnlock()
+	review, ok := m.store.reviewCollection(database, collection, false)[reviewID]
+	if !ok || review.Deleted() {
+		return nil, ErrNoDocument
+	}
+	revisions := Revisions{}
+	for _, revision := range m.store.revisionCollection(database, m.revisionCollection, false) {
+		if revision.ReviewID == reviewID {
+			revisions = append(revisions, revision)
+		}
+	}
+	sort.Slice(revisions, func(i, j int) bool { return revisions[i].Version < revisions[j].Version })
+	return &revisions, nil
+}
+
+// Orphans finds the orphaned reviews in the reviews collection, the reviews not in the trash whose place doesn't exist
+// or is in the trash, takes a context, database name, collection name and whether to move them to the trash.
+func (m MemoryReviewModel) Orphans(ctx context.Context, database, collection string, repair bool) (_ *Reviews, err error) {
+	defer func() { err = wrapError("ReviewModel.Orphans", err) }()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+	places := m.store.placeCollection(database, m.placeCollection, false)
+	reviews := m.store.reviewCollection(database, collection, true)
+	orphans := Reviews{}
+	for _, stored := range reviews {
+		place, ok := places[stored.PlaceID]
+		if stored.Deleted() || (ok && !place.Deleted()) {
+			continue
+		}
+		var review Review
+		if err := copyDocument(stored, &review); err != nil {
+			return nil, err
+		}
+		orphans = append(orphans, review)
+		if !repair {
+			continue
+		}
+		// the orphan of a place in the trash is restored with it.
+		stored.Tombstone = newTombstone(orphanDeletedBy)
+		if ok {
+			stored.Tombstone = place.Tombstone
+		}
+		stored.Version++
+		reviews[stored.ID] = stored
+	}
+	sort.Slice(orphans, func(i, j int) bool { return orphans[i].ID < orphans[j].ID })
+	return &orphans, nil
+}
+
+// Duplicates finds the duplicated reviews in the reviews collection, the reviews of a user on a place but the one kept,
+// takes a context, database name, collection name and whether to delete the duplicates. The review kept is the newest
+// review not in the trash, or the newest review when they are all in the trash.
+func (m MemoryReviewModel) Duplicates(ctx context.Context, database, collection string, repair bool) (_ *Reviews, err error) {
+	defer func() { err = wrapError("ReviewModel.Duplicates", err) }()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+	groups := map[[2]string]Reviews{}
+	for _, stored := range m.store.reviewCollection(database, collection, true) {
+		var review Review
+		if err := copyDocument(stored, &review); err != nil {
+			return nil, err
+		}
+		key := [2]string{review.PlaceID, review.UserID}
+		groups[key] = append(groups[key], review)
+	}
+	duplicates := Reviews{}
+	for _, group := range groups {
+		sort.Slice(group, func(i, j int) bool {
+			if group[i].Deleted() != group[j].Deleted() {
+				return !group[i].Deleted()
+			}
+			if !group[i].CreatedAt.Equal(group[j].CreatedAt) {
+				return group[i].CreatedAt.After(group[j].CreatedAt)
+			}
+			return group[i].ID < group[j].ID
+		})
+		duplicates = append(duplicates, group[1:]...)
+	}
+	sort.Slice(duplicates, func(i, j int) bool { return duplicates[i].ID < duplicates[j].ID })
+	if !repair {
+		return &duplicates, nil
+	}
+	for _, review := range duplicates {
+		m.purgeReview(database, collection, review.ID)
+		m.updateRatingStats(database, collection, review.PlaceID)
+	}
+	return &duplicates, nil
+}
+
+// purgeReview deletes the review for good with its revisions, the caller holds the lock of the store.
+func (m MemoryReviewModel) purgeReview(database, collection string, reviewID string) {
+	delete(m.store.reviewCollection(database, collection, true), reviewID)
+	revisions := m.store.revisionCollection(database, m.revisionCollection, true)
+	for revisionID, revision := range revisions {
+		if revision.ReviewID == reviewID {
+			delete(revisions, revisionID)
+		}
+	}
+}
+
+// livePlace reports whether the place exists and is not in the trash, the caller holds the lock of the store.
+func (m MemoryReviewModel) livePlace(database string, placeID string) bool {
+	place, ok := m.store.placeCollection(database, m.placeCollection, false)[placeID]
+	return ok && !place.Deleted()
+}
+
+// find returns copies of the place reviews of the collection matching the filter, as the reviewQuery does in MongoDB.
+func (m MemoryReviewModel) find(database, collection string, placeID string, filter Filter) (Reviews, error) {
+	m.store.mu.RLock()
+	defer m.store.mu.RUnlock()
+	var reviews Reviews
+	for _, stored := range m.store.reviewCollection(database, collection, false) {
+		if placeID != "" && stored.PlaceID != placeID || stored.Deleted() != filter.Deleted || !filter.matchAfter(stored.CreatedAt.UnixMilli(), stored.ID) {
+			continue
+		}
+		var review Review
+		if err := copyDocument(stored, &review); err != nil {
+			return nil, err
+		}
+		reviews = append(reviews, review)
+	}
+	return reviews, nil
+}
+
+// updateRatingStats recomputes the rating statistics of the place from all its reviews but the ones in the trash,
+// leaving the place version as is. The store must be locked.
+func (m MemoryReviewModel) updateRatingStats(database, collection string, placeID string) {
+	places := m.store.placeCollection(database, m.placeCollection, true)
+	place, ok := places[placeID]
+	if !ok {
+		return
+	}
+	stats := RatingStats{RatingHistogram: make([]int64, 5)}
+	var sum float64
+	for _, review := range m.store.reviewCollection(database, collection, true) {
+		star := int(math.Round(float64(review.Rating)))
+		if review.PlaceID != placeID || review.Deleted() || star < 1 || star > 5 {
+			continue
+		}
+		stats.RatingHistogram[star-1]++
+		stats.ReviewCount++
+		sum += float64(review.Rating)
+	}
+	if stats.ReviewCount > 0 {
+		stats.AverageRating = math.Round(sum/float64(stats.ReviewCount)*100) / 100
+	}
+	place.RatingStats = stats
+	places[place.ID] = place
+}
+
+// matchPlace reports whether the place matches the filter query, as the placeQuery does in MongoDB.
+func (f Filter) matchPlace(place *Place) bool {
+	if len(f.Categories) > 0 && !containsAny(place.Categories, f.Categories) {
+		return false
+	}
+	if f.City != "" && place.Location.Address.City != f.City {
+		return false
+	}
+	if f.State != "" && place.Location.Address.State != f.State {
+		return false
+	}
+	if f.MinRating > 0 && place.AverageRating < f.MinRating {
+		return false
+	}
+	if !f.CreatedAfter.IsZero() && !place.CreatedAt.After(f.CreatedAfter) {
+		return false
+	}
+	if !f.CreatedBefore.IsZero() && !place.CreatedAt.Before(f.CreatedBefore) {
+		return false
+	}
+	if f.UserID != "" && place.UserID != f.UserID {
+		return false
+	}
+	if place.Deleted() != f.Deleted {
+		return false
+	}
+	return f.matchAfter(place.CreatedAt.UnixMilli(), place.ID)
+}
+
+// matchAfter reports whether the document is after the filter cursor in the default order.
+func (f Filter) matchAfter(createdAt int64, id string) bool {
+	return f.After == nil || newerFirst(f.After.CreatedAt.UnixMilli(), f.After.ID, createdAt, id)
+}
+
+// lessPlace reports whether place a is sorted before place b by the filter sort fields, as the sort does in MongoDB.
+func (f Filter) lessPlace(a, b *Place) bool {
+	if len(f.Sort) == 0 {
+		return newerFirst(a.CreatedAt.UnixMilli(), a.ID, b.CreatedAt.UnixMilli(), b.ID)
+	}
+	for _, field := range f.Sort {
+		descending := strings.HasPrefix(field, "-")
+		var cmp int
+		switch strings.TrimPrefix(field, "-") {
+		case "created_at":
+			cmp = compare(a.CreatedAt.UnixMilli(), b.CreatedAt.UnixMilli())
+		case "title":
+			cmp = strings.Compare(a.Title, b.Title)
+		case "average_rating":
+			cmp = compare(a.AverageRating, b.AverageRating)
+		case "review_count":
+			cmp = compare(a.ReviewCount, b.ReviewCount)
+		}
+		if descending {
+			cmp = -cmp
+		}
+		if cmp != 0 {
+			return cmp < 0
+		}
+	}
+	return a.ID < b.ID
+}
+
+// newerFirst reports whether document a is before document b in the default (created_at, _id) descending order.
+func newerFirst(aCreatedAt int64, aID string, bCreatedAt int64, bID string) bool {
+	if aCreatedAt != bCreatedAt {
+		return aCreatedAt > bCreatedAt
+	}
+	return aID > bID
+}
+
+func compare[T int64 | float64](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// paginate applies the filter skip and limit to the sorted documents.
+func paginate[T any, S ~[]T](documents S, filter Filter) *S {
+	if filter.Skip >= len(documents) {
+		documents = nil
+	} else {
+		documents = documents[filter.Skip:]
+	}
+	if filter.Limit > 0 && len(documents) > filter.Limit {
+		documents = documents[:filter.Limit]
+	}
+	return &documents
+}
+
+func containsAny(values, wanted []string) bool {
+	for _, value := range values {
+		for _, w := range wanted {
+			if value == w {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// textWords splits the text into its lower case words.
+func textWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// textScore approximates the text score of the place for the search words with the weights of the text index, the
+// matches of a field are weighed by the field length so that the shorter fields score higher.
+func textScore(place *Place, words []string) float64 {
+	fields := []struct {
+		text   string
+		weight float64
+	}{
+		{place.Title, 10},
+		{strings.Join(place.Categories, " "), 5},
+		{place.Description, 1},
+	}
+	var score float64
+	for _, field := range fields {
+		fieldWords := textWords(field.text)
+		var matches int
+		for _, fieldWord := range fieldWords {
+			for _, word := range words {
+				if fieldWord == word {
+					matches++
+				}
+			}
+		}
+		if matches > 0 {
+			score += field.weight * float64(matches) / float64(len(fieldWords))
+		}
+	}
+	return score
+}
+
+// earthRadius is the radius in meters MongoDB uses for the spherical geometry.
+const earthRadius = 6378100
+
+// sphereDistance returns the great circle distance in meters between two [longitude, latitude] points.
+func sphereDistance(a, b []float64) float64 {
+	lat1, lat2 := a[1]*math.Pi/180, b[1]*math.Pi/180
+	dLat, dLng := lat2-lat1, (b[0]-a[0])*math.Pi/180
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(h))
+}
+
+// inRing reports whether the [longitude, latitude] point is within the closed polygon ring, using the even-odd rule
+// on the planar coordinates which is close enough for the map viewport sized polygons.
+func inRing(point []float64, ring [][]float64) bool {
+	x, y := point[0], point[1]
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		xi, yi, xj, yj := ring[i][0], ring[i][1], ring[j][0], ring[j][1]
+		if (yi > y) != (yj > y) && x < (xj-xi)*(y-yi)/(yj-yi)+xi {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// copyDocument copies the document through its bson encoding, as it is when stored in and read from MongoDB.
+func copyDocument(src, dst interface{}) error {
+	b, err := bson.Marshal(src)
+	if err != nil {
+		return err
+	}
+	return bson.Unmarshal(b, dst)
+}
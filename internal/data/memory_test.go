@@ -0,0 +1,98 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMemoryDuplicates(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	places := NewMemoryPlaceModel(store, "reviews")
+	reviews := NewMemoryReviewModel(store, "places", "revisions")
+	if err := places.InsertOne(ctx, "test", "places", &Place{ID: "place-1", UserID: "owner"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the reviews created before the unique index of the reviews, review-3 is the newest but in the trash.
+	now := time.Now()
+	deletedAt := now
+	stored := store.reviewCollection("test", "reviews", true)
+	stored["review-1"] = Review{ID: "review-1", PlaceID: "place-1", UserID: "author", Rating: 1, CreatedAt: now.Add(-2 * time.Hour), Version: 1}
+	stored["review-2"] = Review{ID: "review-2", PlaceID: "place-1", UserID: "author", Rating: 5, CreatedAt: now.Add(-time.Hour), Version: 1}
+	stored["review-3"] = Review{ID: "review-3", PlaceID: "place-1", UserID: "author", Rating: 3, CreatedAt: now, Version: 1, Tombstone: Tombstone{DeletedAt: &deletedAt}}
+	stored["review-4"] = Review{ID: "review-4", PlaceID: "place-1", UserID: "other", Rating: 2, CreatedAt: now, Version: 1}
+
+	duplicates, err := reviews.Duplicates(ctx, "test", "reviews", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := idsOfReviews(*duplicates); len(got) != 2 || got[0] != "review-1" || got[1] != "review-3" {
+		t.Fatalf("got duplicates %v; want [review-1 review-3]", got)
+	}
+	if len(stored) != 4 {
+		t.Fatalf("check: got %d reviews; want 4", len(stored))
+	}
+
+	if _, err := reviews.Duplicates(ctx, "test", "reviews", true); err != nil {
+		t.Fatal(err)
+	}
+	if duplicates, err := reviews.Duplicates(ctx, "test", "reviews", false); err != nil || len(*duplicates) != 0 {
+		t.Fatalf("repaired: got duplicates %v (err %v); want none", idsOfReviews(*duplicates), err)
+	}
+	place, err := places.FindOne(ctx, "test", "places", "place-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if place.ReviewCount != 2 || place.AverageRating != 3.5 {
+		t.Errorf("got %d reviews with %v average rating; want 2 reviews with 3.5", place.ReviewCount, place.AverageRating)
+	}
+}
+
+func TestMemoryRatingStats(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	places := NewMemoryPlaceModel(store, "reviews")
+	reviews := NewMemoryReviewModel(store, "places", "revisions")
+	if err := places.InsertOne(ctx, "test", "places", &Place{ID: "place-1", UserID: "owner"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the ratings are rounded to the nearest star in the histogram, the average is rounded to two decimals.
+	for i, rating := range []float32{1, 2.4, 2.5, 4.6, 5} {
+		review := Review{ID: fmt.Sprintf("review-%d", i+1), PlaceID: "place-1", UserID: fmt.Sprintf("author-%d", i+1), Rating: rating}
+		if err := reviews.InsertOne(ctx, "test", "reviews", &review); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the reviews in the trash are not counted.
+	if err := reviews.DeleteOne(ctx, "test", "reviews", "review-5", 1, "author-5"); err != nil {
+		t.Fatal(err)
+	}
+
+	place, err := places.FindOne(ctx, "test", "places", "place-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int64{1, 1, 1, 0, 1}; !reflect.DeepEqual(place.RatingHistogram, want) {
+		t.Errorf("got histogram %v; want %v", place.RatingHistogram, want)
+	}
+	if place.ReviewCount != 4 || place.AverageRating != 2.63 {
+		t.Errorf("got %d reviews with %v average rating; want 4 reviews with 2.63", place.ReviewCount, place.AverageRating)
+	}
+	// the statistics are derived from the reviews, they don't change the version of the place.
+	if place.Version != 1 {
+		t.Errorf("got place version %d; want 1", place.Version)
+	}
+}
+
+func idsOfReviews(reviews Reviews) []string {
+	ids := make([]string, 0, len(reviews))
+	for _, review := range reviews {
+		ids = append(ids, review.ID)
+	}
+	return ids
+}
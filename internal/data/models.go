@@ -2,19 +2,20 @@ package data
 
 import (
 	"context"
+	"time"
 
-	firebase "firebase.google.com/go/v4"
 	"firebase.google.com/go/v4/auth"
 )
 
 type Models struct {
 	Place interface {
 		// InsertOne inserts a new document to the places collection, takes a context, database name, collection name
-		// and pointer to place struct object with the data to be inserted.
+		// and pointer to place struct object with the data to be inserted. The version of the new place is 1.
 		InsertOne(ctx context.Context, database, collection string, place *Place) error
 
 		// UpdateOne updated a specific place document in the places collection, takes a context, database name, collection name
-		// and pointer to place struct objet with data to be updated.
+		// and pointer to place struct objet with data to be updated. The fields left empty are removed from the document.
+		// ErrConflict is returned when the version of the place is not the stored version, otherwise it is incremented.
 		UpdateOne(ctx context.Context, database, collection string, place *Place) error
 
 		// FindOne finds a specific places document in the places collection, takes a context, database name, collection name
@@ -25,22 +26,45 @@ type Models struct {
 		// and filter.
 		List(ctx context.Context, database, collection string, filter Filter) (*Places, error)
 
+		// Count counts the places documents in the places collection, takes a context, database name, collection name
+		// and filter.
+		Count(ctx context.Context, database, collection string, filter Filter) (int64, error)
+
 		// DeleteOne deletes a specific place document in the places collection, takes a context, database name, collection name
-		// and document id.
-		DeleteOne(ctx context.Context, database, collection string, placeID string) error
+		// document id and version and the id of the user deleting it. The place is moved to the trash, it is kept with the
+		// deleted_at and deleted_by tombstone until it is restored or purged, and it is no longer found by the other methods.
+		// The reviews of the place are moved to the trash with the same tombstone. ErrConflict is returned when the version
+		// of the place is not the stored version.
+		DeleteOne(ctx context.Context, database, collection string, placeID string, version int64, deletedBy string) error
+
+		// Restore restores a specific place document from the trash, takes a context, database name, collection name and
+		// document id. The reviews moved to the trash with the place are restored, not the ones deleted before it.
+		Restore(ctx context.Context, database, collection string, placeID string) error
+
+		// Purge deletes the place documents moved to the trash before the given time for good, takes a context, database
+		// name, collection name and the time. The number of purged places is returned.
+		Purge(ctx context.Context, database, collection string, before time.Time) (int64, error)
 
 		// SearchPlace searches place documents in places collection by search term, takes a context, database name, collection name
 		// search term and filter.
 		SearchPlace(ctx context.Context, database, collection string, term string, filter Filter) (*Places, error)
+
+		// Nearby finds place documents in places collection by location, takes a context, database name, collection name
+		// geo query and filter.
+		Nearby(ctx context.Context, database, collection string, query GeoQuery, filter Filter) (*Places, error)
 	}
 
 	Review interface {
 		// InsertOne inserts a new document to the reviews collection, takes a context, database name, collection name
-		// and pointer to place struct object with the data to be inserted.
+		// and pointer to place struct object with the data to be inserted. The version of the new review is 1.
+		// ErrValidation is returned when the reviewed place doesn't exist or is in the trash, and ErrConflict when the
+		// user already reviewed the place, including with a review in the trash.
 		InsertOne(ctx context.Context, database, collection string, review *Review) error
 
 		// UpdateOne updates a specific review document in the reviews collection, takes a context, database name, collection name
-		// and pointer to review struct objet with data to be updated.
+		// and pointer to review struct objet with data to be updated. The fields left empty are removed from the document.
+		// ErrConflict is returned when the version of the review is not the stored version, otherwise it is incremented
+		// and the replaced version is saved as a revision.
 		UpdateOne(ctx context.Context, database, collection string, review *Review) error
 
 		// FindOne finds a specific review document in the reviews collection, takes a context, database name, collection name
@@ -48,40 +72,76 @@ type Models struct {
 		FindOne(ctx context.Context, database, collection string, reviewID string) (*Review, error)
 
 		// List finds all reviews documents in the reviews collections by place id, takes a context, database name
-		// collection name and filter.
+		// collection name and filter. An empty place id matches the reviews of every place.
 		List(ctx context.Context, database, collection string, placeID string, filter Filter) (*Reviews, error)
 
+		// Count counts the reviews documents in the reviews collection by place id, takes a context, database name
+		// collection name and filter.
+		Count(ctx context.Context, database, collection string, placeID string, filter Filter) (int64, error)
+
 		// DeleteOne deletes a specific review document in the reviews collection, takes a context, database name, collection name
-		// and document id.
-		DeleteOne(ctx context.Context, database, collection string, reviewID string) error
+		// document id and version and the id of the user deleting it. The review is moved to the trash like the places.
+		// ErrConflict is returned when the version of the review is not the stored version.
+		DeleteOne(ctx context.Context, database, collection string, reviewID string, version int64, deletedBy string) error
+
+		// Restore restores a specific review document from the trash, takes a context, database name, collection name and
+		// document id. ErrConflict is returned when the place of the review is in the trash.
+		Restore(ctx context.Context, database, collection string, reviewID string) error
+
+		// Purge deletes the review documents moved to the trash before the given time for good, takes a context, database
+		// name, collection name and the time. The revisions of the purged reviews are deleted, the number of purged
+		// reviews is returned.
+		Purge(ctx context.Context, database, collection string, before time.Time) (int64, error)
+
+		// SetReply sets the reply of a specific review document in the reviews collection, takes a context, database name,
+		// collection name, the review id and version and the reply, a nil reply deletes the reply. ErrConflict is returned
+		// when the version of the review is not the stored version, otherwise it is incremented.
+		SetReply(ctx context.Context, database, collection string, reviewID string, version int64, reply *Reply) error
+
+		// History finds the revisions of a specific review, takes a context, database name, reviews collection name and
+		// the review id. The revisions are sorted from the oldest, the current version of the review is not included.
+		History(ctx context.Context, database, collection string, reviewID string) (*Revisions, error)
+
+		// Orphans finds the orphaned reviews in the reviews collection, the reviews not in the trash whose place doesn't
+		// exist or is in the trash, takes a context, database name, collection name and whether to move the orphans
+		// to the trash. The orphans found are returned.
+		Orphans(ctx context.Context, database, collection string, repair bool) (*Reviews, error)
+
+		// Duplicates finds the duplicated reviews in the reviews collection, the reviews of a user on a place but the
+		// newest one, the reviews not in the trash are kept first. Takes a context, database name, collection name and
+		// whether to delete the duplicates for good. The duplicates found are returned.
+		Duplicates(ctx context.Context, database, collection string, repair bool) (*Reviews, error)
 	}
 
 	Auth interface {
-		// VerifyIDToken verifys a token id, takes context, firebase app and id token.
-		VerifyIDToken(ctx context.Context, app *firebase.App, idToken string) (*auth.Token, error)
+		// VerifyIDToken verifys a token id, takes context and id token.
+		VerifyIDToken(ctx context.Context, idToken string) (*auth.Token, error)
+
+		// RevokeRefreshTokens revokes refresh token associated by a user account, takes context and user uid.
+		RevokeRefreshTokens(ctx context.Context, uid string) error
 
-		// RevokeRefreshTokens revokes refresh token associated by a user account, takes context, firebase app and user uid.
-		RevokeRefreshTokens(ctx context.Context, app *firebase.App, uid string) error
+		// GetUser gets user by id, takes context and user uid.
+		GetUser(ctx context.Context, uid string) (*auth.UserRecord, error)
 
-		// GetUser gets user by id, takes context, firebase app and user uid.
-		GetUser(ctx context.Context, app *firebase.App, uid string) (*auth.UserRecord, error)
+		// GetUserByEmail gets user by email, takes context and email.
+		GetUserByEmail(ctx context.Context, email string) (*auth.UserRecord, error)
 
-		// GetUserByEmail gets user by email, takes context, firebase app and email.
-		GetUserByEmail(ctx context.Context, app *firebase.App, email string) (*auth.UserRecord, error)
+		// GetUserByPhone gets user by phone, takes context and phone.
+		GetUserByPhone(ctx context.Context, phone string) (*auth.UserRecord, error)
 
-		// GetUserByPhone gets user by phone, takes context, firebase app and phone.
-		GetUserByPhone(ctx context.Context, app *firebase.App, phone string) (*auth.UserRecord, error)
+		// CreateUser creates a new user, takes a context and user object.
+		CreateUser(ctx context.Context, user *User) (*auth.UserRecord, error)
 
-		// CreateUser creates a new user, takes a context, firebase app and user object.
-		CreateUser(ctx context.Context, app *firebase.App, user *User) (*auth.UserRecord, error)
+		// UpdateUser updates an existing user, takes context and user object.
+		UpdateUser(ctx context.Context, user *User) (*auth.UserRecord, error)
 
-		// UpdateUser updates an existing user, takes context, firebase app and user object.
-		UpdateUser(ctx context.Context, app *firebase.App, user *User) (*auth.UserRecord, error)
+		// DeleteUser delete a user by id, takes a context and user uid.
+		DeleteUser(ctx context.Context, uid string) error
 
-		// DeleteUser delete a user by id, takes a context, firebase app and user uid.
-		DeleteUser(ctx context.Context, app *firebase.App, uid string) error
+		// CustomClaimsSet sets custom claims to a user, takes context, uid and claims map.
+		CustomClaimsSet(ctx context.Context, uid string, claims map[string]interface{}) error
 
-		// CustomClaimsSet sets custom claims to a user, takes context firebase app, uid and claims map.
-		CustomClaimsSet(ctx context.Context, app *firebase.App, uid string, claims map[string]interface{}) error
+		// Check checks that the auth provider is configured to verify the id tokens, takes a context.
+		Check(ctx context.Context) error
 	}
 }
@@ -0,0 +1,188 @@
+package data
+
+import (
+	"context"
+	"time"
+)
+
+// Observer observes the calls of the model methods, e.g. to record the metrics or the traces of the database
+// operations. Observe is called before the method with the model and method names, the returned context is passed
+// to the method and the returned function is called with the error returned by the method once it is done, the
+// observers record the errors for which IsFailure is true as failures.
+type Observer interface {
+	Observe(ctx context.Context, model, method string) (context.Context, func(err error))
+}
+
+// Observe returns the models with the calls of the place and review model methods observed by the observer, the
+// models can be observed by several observers by observing the returned models again.
+func Observe(models Models, observer Observer) Models {
+	models.Place = observedPlaceModel{models: models, observer: observer}
+	models.Review = observedReviewModel{models: models, observer: observer}
+	return models
+}
+
+// observedPlaceModel observes the calls of the place model of models.
+type observedPlaceModel struct {
+	models   Models
+	observer Observer
+}
+
+func (p observedPlaceModel) InsertOne(ctx context.Context, database, collection string, place *Place) error {
+	ctx, done := p.observer.Observe(ctx, "PlaceModel", "InsertOne")
+	err := p.models.Place.InsertOne(ctx, database, collection, place)
+	done(err)
+	return err
+}
+
+func (p observedPlaceModel) UpdateOne(ctx context.Context, database, collection string, place *Place) error {
+	ctx, done := p.observer.Observe(ctx, "PlaceModel", "UpdateOne")
+	err := p.models.Place.UpdateOne(ctx, database, collection, place)
+	done(err)
+	return err
+}
+
+func (p observedPlaceModel) FindOne(ctx context.Context, database, collection string, placeID string) (*Place, error) {
+	ctx, done := p.observer.Observe(ctx, "PlaceModel", "FindOne")
+	place, err := p.models.Place.FindOne(ctx, database, collection, placeID)
+	done(err)
+	return place, err
+}
+
+func (p observedPlaceModel) List(ctx context.Context, database, collection string, filter Filter) (*Places, error) {
+	ctx, done := p.observer.Observe(ctx, "PlaceModel", "List")
+	places, err := p.models.Place.List(ctx, database, collection, filter)
+	done(err)
+	return places, err
+}
+
+func (p observedPlaceModel) Count(ctx context.Context, database, collection string, filter Filter) (int64, error) {
+	ctx, done := p.observer.Observe(ctx, "PlaceModel", "Count")
+	count, err := p.models.Place.Count(ctx, database, collection, filter)
+	done(err)
+	return count, err
+}
+
+func (p observedPlaceModel) DeleteOne(ctx context.Context, database, collection string, placeID string, version int64, deletedBy string) error {
+	ctx, done := p.observer.Observe(ctx, "PlaceModel", "DeleteOne")
+	err := p.models.Place.DeleteOne(ctx, database, collection, placeID, version, deletedBy)
+	done(err)
+	return err
+}
+
+func (p observedPlaceModel) Restore(ctx context.Context, database, collection string, placeID string) error {
+	ctx, done := p.observer.Observe(ctx, "PlaceModel", "Restore")
+	err := p.models.Place.Restore(ctx, database, collection, placeID)
+	done(err)
+	return err
+}
+
+func (p observedPlaceModel) Purge(ctx context.Context, database, collection string, before time.Time) (int64, error) {
+	ctx, done := p.observer.Observe(ctx, "PlaceModel", "Purge")
+	count, err := p.models.Place.Purge(ctx, database, collection, before)
+	done(err)
+	return count, err
+}
+
+func (p observedPlaceModel) SearchPlace(ctx context.Context, database, collection string, term string, filter Filter) (*Places, error) {
+	ctx, done := p.observer.Observe(ctx, "PlaceModel", "SearchPlace")
+	places, err := p.models.Place.SearchPlace(ctx, database, collection, term, filter)
+	done(err)
+	return places, err
+}
+
+func (p observedPlaceModel) Nearby(ctx context.Context, database, collection string, query GeoQuery, filter Filter) (*Places, error) {
+	ctx, done := p.observer.Observe(ctx, "PlaceModel", "Nearby")
+	places, err := p.models.Place.Nearby(ctx, database, collection, query, filter)
+	done(err)
+	return places, err
+}
+
+// observedReviewModel observes the calls of the review model of models.
+type observedReviewModel struct {
+	models   Models
+	observer Observer
+}
+
+func (r observedReviewModel) InsertOne(ctx context.Context, database, collection string, review *Review) error {
+	ctx, done := r.observer.Observe(ctx, "ReviewModel", "InsertOne")
+	err := r.models.Review.InsertOne(ctx, database, collection, review)
+	done(err)
+	return err
+}
+
+func (r observedReviewModel) UpdateOne(ctx context.Context, database, collection string, review *Review) error {
+	ctx, done := r.observer.Observe(ctx, "ReviewModel", "UpdateOne")
+	err := r.models.Review.UpdateOne(ctx, database, collection, review)
+	done(err)
+	return err
+}
+
+func (r observedReviewModel) FindOne(ctx context.Context, database, collection string, reviewID string) (*Review, error) {
+	ctx, done := r.observer.Observe(ctx, "ReviewModel", "FindOne")
+	review, err := r.models.Review.FindOne(ctx, database, collection, reviewID)
+	done(err)
+	return review, err
+}
+
+func (r observedReviewModel) List(ctx context.Context, database, collection string, placeID string, filter Filter) (*Reviews, error) {
+	ctx, done := r.observer.Observe(ctx, "ReviewModel", "List")
+	reviews, err := r.models.Review.List(ctx, database, collection, placeID, filter)
+	done(err)
+	return reviews, err
+}
+
+func (r observedReviewModel) Count(ctx context.Context, database, collection string, placeID string, filter Filter) (int64, error) {
+	ctx, done := r.observer.Observe(ctx, "ReviewModel", "Count")
+	count, err := r.models.Review.Count(ctx, database, collection, placeID, filter)
+	done(err)
+	return count, err
+}
+
+func (r observedReviewModel) DeleteOne(ctx context.Context, database, collection string, reviewID string, version int64, deletedBy string) error {
+	ctx, done := r.observer.Observe(ctx, "ReviewModel", "DeleteOne")
+	err := r.models.Review.DeleteOne(ctx, database, collection, reviewID, version, deletedBy)
+	done(err)
+	return err
+}
+
+func (r observedReviewModel) Restore(ctx context.Context, database, collection string, reviewID string) error {
+	ctx, done := r.observer.Observe(ctx, "ReviewModel", "Restore")
+	err := r.models.Review.Restore(ctx, database, collection, reviewID)
+	done(err)
+	return err
+}
+
+func (r observedReviewModel) Purge(ctx context.Context, database, collection string, before time.Time) (int64, error) {
+	ctx, done := r.observer.Observe(ctx, "ReviewModel", "Purge")
+	count, err := r.models.Review.Purge(ctx, database, collection, before)
+	done(err)
+	return count, err
+}
+
+func (r observedReviewModel) Orphans(ctx context.Context, database, collection string, repair bool) (*Reviews, error) {
+	ctx, done := r.observer.Observe(ctx, "ReviewModel", "Orphans")
+	reviews, err := r.models.Review.Orphans(ctx, database, collection, repair)
+	done(err)
+	return reviews, err
+}
+
+func (r observedReviewModel) Duplicates(ctx context.Context, database, collection string, repair bool) (*Reviews, error) {
+	ctx, done := r.observer.Observe(ctx, "ReviewModel", "Duplicates")
+	reviews, err := r.models.Review.Duplicates(ctx, database, collection, repair)
+	done(err)
+	return reviews, err
+}
+
+func (r observedReviewModel) History(ctx context.Context, database, collection string, reviewID string) (*Revisions, error) {
+	ctx, done := r.observer.Observe(ctx, "ReviewModel", "History")
+	revisions, err := r.models.Review.History(ctx, database, collection, reviewID)
+	done(err)
+	return revisions, err
+}
+
+func (r observedReviewModel) SetReply(ctx context.Context, database, collection string, reviewID string, version int64, reply *Reply) error {
+	ctx, done := r.observer.Observe(ctx, "ReviewModel", "SetReply")
+	err := r.models.Review.SetReply(ctx, database, collection, reviewID, version, reply)
+	done(err)
+	return err
+}
@@ -21,10 +21,30 @@ type Place struct {
 	PhoneNumber string   `json:"phone_number,omitempty" bson:"phone_number,omitempty"`
 	Email       string   `json:"email,omitempty" bson:"email,omitempty"`
 	Location    struct {
-		Address Address `json:"address,omitempty"`
-		Geo     Geo     `json:"geo,omitempty"`
-	} `json:"location,omitempty"`
+		Address Address `json:"address,omitempty" bson:"address"`
+		Geo     Geo     `json:"geo,omitempty" bson:"geo,omitempty"`
+	} `json:"location,omitempty" bson:"location"`
 	CreatedAt time.Time `json:"created_at,omitempty" bson:"created_at,omitempty"`
+	UpdatedAt time.Time `json:"updated_at,omitempty" bson:"updated_at,omitempty"`
+	// Version is incremented on each write of the place, but not on the updates of its rating statistics which are
+	// derived from the reviews.
+	Version int64 `json:"version,omitempty" bson:"version,omitempty"`
+	// Tombstone is set once the place is moved to the trash.
+	Tombstone `bson:",inline"`
+	// RatingStats are maintained by the review model, they can't be set by the clients.
+	RatingStats `bson:",inline"`
+	// Score is the text search relevance score, it is only set on the results of SearchPlace.
+	Score float64 `json:"score,omitempty" bson:"score,omitempty"`
+	// Distance is the distance in meters from the point of a geo query, it is only set on the results of Nearby.
+	Distance float64 `json:"distance,omitempty" bson:"distance,omitempty"`
+}
+
+// resetMaintained resets the fields of a new place maintained by the models, the clients can't create a place in the
+// trash or at another version, and the score and distance are only set on the query results.
+func (p *Place) resetMaintained() {
+	p.Tombstone = Tombstone{}
+	p.Version = 1
+	p.Score, p.Distance = 0, 0
 }
 
 type Address struct {
@@ -34,59 +54,108 @@ type Address struct {
 	ZipCode string `json:"zip_code,omitempty" bson:"zip_code,omitempty"`
 }
 
+// Geo is a GeoJSON point of the place location, the coordinates are in the [longitude, latitude] order.
 type Geo struct {
 	Type        string    `json:"type,omitempty" bson:"type,omitempty"`
 	Coordinates []float64 `json:"coordinates,omitempty" bson:"coordinates,omitempty"`
 }
 
+// IsZero reports whether the geo point is unset, so that an empty point is omitted from the document instead of
+// being stored as an empty GeoJSON object the 2dsphere index can't extract keys from.
+func (g Geo) IsZero() bool { return g.Type == "" && len(g.Coordinates) == 0 }
+
 type Places []Place
 
 type PlaceModel struct {
 	client *mongo.Client
+	// reviewCollection is the name of the reviews collection the reviews of the places are moved to the trash and
+	// restored in with their place, it is in the same database as the places collection.
+	reviewCollection string
 }
 
-func NewPlaceModel(client *mongo.Client) *PlaceModel { return &PlaceModel{client: client} }
+func NewPlaceModel(client *mongo.Client, reviewCollection string) *PlaceModel {
+	return &PlaceModel{client: client, reviewCollection: reviewCollection}
+}
 
-// InsertOne inserts a new document to the places collection, takes a context, database name, collection name
-// and pointer to place struct object with the data to be inserted.
-func (p PlaceModel) InsertOne(ctx context.Context, database, collection string, place *Place) error {
-	var result *mongo.InsertOneResult
+// CreateIndexes creates the indexes required by the place queries in the places collection, takes a context,
+// database name and collection name. Creating an index that already exists is a no-op. The empty locations stored
+// before Geo implemented IsZero are removed first, the geospatial index can't be created while any is left.
+func (p PlaceModel) CreateIndexes(ctx context.Context, database, collection string) error {
 	coll := p.client.Database(database).Collection(collection)
-	result, err := coll.InsertOne(ctx, place)
+	_, err := coll.UpdateMany(ctx,
+		bson.D{{Key: "location.geo", Value: bson.D{}}},
+		bson.D{{Key: "$unset", Value: bson.D{{Key: "location.geo", Value: ""}}}},
+	)
 	if err != nil {
 		return err
 	}
-	if result.InsertedID.(string) != place.ID {
-		return err
-	}
-	return nil
+	_, err = coll.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			// the text index used by SearchPlace, matches on the title weigh more than the categories and the
+			// description.
+			Keys: bson.D{
+				{Key: "title", Value: "text"},
+				{Key: "description", Value: "text"},
+				{Key: "categories", Value: "text"},
+			},
+			Options: options.Index().SetName("places_text").SetWeights(bson.D{
+				{Key: "title", Value: 10},
+				{Key: "categories", Value: 5},
+				{Key: "description", Value: 1},
+			}),
+		},
+		{
+			// the geospatial index used by Nearby, places without a location are not indexed.
+			Keys:    bson.D{{Key: "location.geo", Value: "2dsphere"}},
+			Options: options.Index().SetName("places_geo"),
+		},
+	})
+	return err
 }
 
-// UpdateOne updated a specific place document in the places collection, takes a context, database name, collection name
-// and pointer to place struct objet with data to be updated.
-func (p PlaceModel) UpdateOne(ctx context.Context, database, collection string, place *Place) error {
-	var result *mongo.UpdateResult
+// InsertOne inserts a new document to the places collection, takes a context, database name, collection name
+// and pointer to place struct object with the data to be inserted.
+func (p PlaceModel) InsertOne(ctx context.Context, database, collection string, place *Place) (err error) {
+	defer func() { err = wrapError("PlaceModel.InsertOne", err) }()
+	place.resetMaintained()
 	coll := p.client.Database(database).Collection(collection)
-	result, err := coll.UpdateOne(ctx, bson.M{"_id": place.ID}, bson.D{{Key: "$set", Value: place}})
+	_, err = coll.InsertOne(ctx, place)
+	return err
+}
+
+// UpdateOne updated a specific place document in the places collection, takes a context, database name, collection name
+// and pointer to place struct objet with data to be updated. The place is the whole updated document, the fields left
+// empty are removed from the document while the owner, creation time and rating statistics are kept. The update only
+// applies to the version of the place, ErrConflict is returned when the place was written since it was read. On
+// success the version of the place is incremented.
+func (p PlaceModel) UpdateOne(ctx context.Context, database, collection string, place *Place) (err error) {
+	defer func() { err = wrapError("PlaceModel.UpdateOne", err) }()
+	version := place.Version
+	place.Version++
+	update, err := updateDocument(place, placeProtectedFields)
 	if err != nil {
 		return err
 	}
-	if result.MatchedCount != 1 || result.UpsertedCount != 1 {
+	var result *mongo.UpdateResult
+	coll := p.client.Database(database).Collection(collection)
+	result, err = coll.UpdateOne(ctx, versionFilter(place.ID, version), update)
+	if err != nil {
 		return err
 	}
-	if result.UpsertedID.(string) != place.ID {
-		return err
+	if result.MatchedCount == 0 {
+		return missingOrConflict(ctx, coll, place.ID)
 	}
 	return nil
 }
 
 // FindOne finds a specific places document in the places collection, takes a context, database name, collection name
 // and the document id
-func (p PlaceModel) FindOne(ctx context.Context, database, collection string, placeID string) (*Place, error) {
+func (p PlaceModel) FindOne(ctx context.Context, database, collection string, placeID string) (_ *Place, err error) {
+	defer func() { err = wrapError("PlaceModel.FindOne", err) }()
 	var result *mongo.SingleResult
 	var place Place
 	coll := p.client.Database(database).Collection(collection)
-	result = coll.FindOne(ctx, bson.M{"_id": placeID})
+	result = coll.FindOne(ctx, liveFilter(placeID))
 	if err := result.Decode(&place); err != nil {
 		return nil, err
 	}
@@ -95,10 +164,11 @@ func (p PlaceModel) FindOne(ctx context.Context, database, collection string, pl
 
 // List finds all places documents in the places collections, takes a context, database name, collection name
 // and filter.
-func (p PlaceModel) List(ctx context.Context, database, collection string, filter Filter) (*Places, error) {
-	opts := options.Find().SetSkip(int64(filter.Skip)).SetLimit(int64(filter.Limit))
+func (p PlaceModel) List(ctx context.Context, database, collection string, filter Filter) (_ *Places, err error) {
+	defer func() { err = wrapError("PlaceModel.List", err) }()
+	opts := options.Find().SetSkip(int64(filter.Skip)).SetLimit(int64(filter.Limit)).SetSort(filter.sort())
 	coll := p.client.Database(database).Collection(collection)
-	filterCursor, err := coll.Find(ctx, bson.M{}, opts)
+	filterCursor, err := coll.Find(ctx, filter.placeQuery(), opts)
 	if err != nil {
 		return nil, err
 	}
@@ -110,27 +180,73 @@ func (p PlaceModel) List(ctx context.Context, database, collection string, filte
 		}
 		places = append(places, place)
 	}
+	if err := filterCursor.Err(); err != nil {
+		return nil, err
+	}
 	return &places, nil
 }
 
+// Count counts the places documents in the places collection, takes a context, database name, collection name
+// and filter.
+func (p PlaceModel) Count(ctx context.Context, database, collection string, filter Filter) (_ int64, err error) {
+	defer func() { err = wrapError("PlaceModel.Count", err) }()
+	coll := p.client.Database(database).Collection(collection)
+	return coll.CountDocuments(ctx, filter.placeQuery())
+}
+
 // DeleteOne deletes a specific place document in the places collection, takes a context, database name, collection name
-// and document id.
-func (p PlaceModel) DeleteOne(ctx context.Context, database, collection string, placeID string) error {
-	var result *mongo.DeleteResult
+// document id and version and the id of the user deleting it. The place is moved to the trash with its reviews.
+func (p PlaceModel) DeleteOne(ctx context.Context, database, collection string, placeID string, version int64, deletedBy string) (err error) {
+	defer func() { err = wrapError("PlaceModel.DeleteOne", err) }()
+	var result *mongo.UpdateResult
 	coll := p.client.Database(database).Collection(collection)
-	result, err := coll.DeleteOne(ctx, bson.M{"_id": placeID})
+	tombstone := newTombstone(deletedBy)
+	result, err = coll.UpdateOne(ctx, versionFilter(placeID, version), tombstoneUpdate(tombstone))
 	if err != nil {
 		return err
 	}
-	if result.DeletedCount != 1 {
+	if result.MatchedCount == 0 {
+		return missingOrConflict(ctx, coll, placeID)
+	}
+	// the reviews share the tombstone of the place, so that restoring the place only restores the reviews deleted
+	// with it. The reviews left behind by a failure are reported by the consistency check.
+	reviews := p.client.Database(database).Collection(p.reviewCollection)
+	_, err = reviews.UpdateMany(ctx, liveReviewsFilter(placeID), tombstoneUpdate(tombstone))
+	return err
+}
+
+// Restore restores a specific place document from the trash, takes a context, database name, collection name and
+// document id. The reviews moved to the trash with the place are restored with it.
+func (p PlaceModel) Restore(ctx context.Context, database, collection string, placeID string) (err error) {
+	defer func() { err = wrapError("PlaceModel.Restore", err) }()
+	var existing Place
+	coll := p.client.Database(database).Collection(collection)
+	// the place is returned as it was before the update, with the tombstone its reviews are found by.
+	result := coll.FindOneAndUpdate(ctx, trashedFilter(placeID), restoreUpdate())
+	if err := result.Decode(&existing); err != nil {
 		return err
 	}
-	return nil
+	reviews := p.client.Database(database).Collection(p.reviewCollection)
+	_, err = reviews.UpdateMany(ctx, cascadedReviewsFilter(placeID, existing.Tombstone), restoreUpdate())
+	return err
+}
+
+// Purge deletes the place documents moved to the trash before the given time for good, takes a context, database
+// name, collection name and the time.
+func (p PlaceModel) Purge(ctx context.Context, database, collection string, before time.Time) (_ int64, err error) {
+	defer func() { err = wrapError("PlaceModel.Purge", err) }()
+	coll := p.client.Database(database).Collection(collection)
+	result, err := coll.DeleteMany(ctx, purgeFilter(before))
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
 }
 
 // SearchPlace searches place documents in places collection by search term, takes a context, database name, collection name
 // search term and filter.
-func (p PlaceModel) SearchPlace(ctx context.Context, database, collection string, term string, filter Filter) (*Places, error) {
+func (p PlaceModel) SearchPlace(ctx context.Context, database, collection string, term string, filter Filter) (_ *Places, err error) {
+	defer func() { err = wrapError("PlaceModel.SearchPlace", err) }()
 	sort := bson.D{{Key: "score", Value: bson.D{{Key: "$meta", Value: "textScore"}}}}
 	projection := bson.D{
 		{Key: "_id", Value: 1},
@@ -143,10 +259,15 @@ func (p PlaceModel) SearchPlace(ctx context.Context, database, collection string
 		{Key: "email", Value: 1},
 		{Key: "location", Value: 1},
 		{Key: "created_at", Value: 1},
+		{Key: "updated_at", Value: 1},
+		{Key: "version", Value: 1},
+		{Key: "review_count", Value: 1},
+		{Key: "average_rating", Value: 1},
+		{Key: "rating_histogram", Value: 1},
 		{Key: "score", Value: bson.D{{Key: "$meta", Value: "textScore"}}},
 	}
 	opts := options.Find().SetSkip(int64(filter.Skip)).SetLimit(int64(filter.Limit)).SetProjection(projection).SetSort(sort)
-	filt := bson.D{{Key: "$text", Value: bson.D{{Key: "$search", Value: term}}}}
+	filt := append(bson.D{{Key: "$text", Value: bson.D{{Key: "$search", Value: term}}}}, filter.placeQuery()...)
 	coll := p.client.Database(database).Collection(collection)
 	filterCursor, err := coll.Find(ctx, filt, opts)
 	if err != nil {
@@ -160,5 +281,62 @@ func (p PlaceModel) SearchPlace(ctx context.Context, database, collection string
 		}
 		places = append(places, place)
 	}
+	if err := filterCursor.Err(); err != nil {
+		return nil, err
+	}
+	return &places, nil
+}
+
+// Nearby finds place documents in places collection by location, takes a context, database name, collection name
+// geo query and filter. When the query has a Near point the places are sorted from the nearest and carry their
+// distance in meters, otherwise only the places within the Box or Polygon of the query are matched.
+func (p PlaceModel) Nearby(ctx context.Context, database, collection string, query GeoQuery, filter Filter) (_ *Places, err error) {
+	defer func() { err = wrapError("PlaceModel.Nearby", err) }()
+	match := filter.placeQuery()
+	if within := query.within(); within != nil {
+		match = append(match, bson.E{Key: "location.geo", Value: bson.D{{Key: "$geoWithin", Value: bson.D{{Key: "$geometry", Value: within}}}}})
+	}
+
+	var pipeline mongo.Pipeline
+	if query.Near != nil {
+		// $geoNear must be the first stage of the pipeline, it uses the 2dsphere index to sort the places by the
+		// spherical distance and adds the distance in meters to each document.
+		geoNear := bson.D{
+			{Key: "near", Value: Geo{Type: "Point", Coordinates: query.Near}},
+			{Key: "distanceField", Value: "distance"},
+			{Key: "key", Value: "location.geo"},
+			{Key: "spherical", Value: true},
+			{Key: "query", Value: match},
+		}
+		if query.MaxDistance > 0 {
+			geoNear = append(geoNear, bson.E{Key: "maxDistance", Value: query.MaxDistance})
+		}
+		pipeline = append(pipeline, bson.D{{Key: "$geoNear", Value: geoNear}})
+	} else {
+		// the places within a box or polygon are paged in the order of the listings, so that no place is repeated
+		// or skipped across the pages.
+		pipeline = append(pipeline, bson.D{{Key: "$match", Value: match}}, bson.D{{Key: "$sort", Value: filter.sort()}})
+	}
+	pipeline = append(pipeline,
+		bson.D{{Key: "$skip", Value: int64(filter.Skip)}},
+		bson.D{{Key: "$limit", Value: int64(filter.Limit)}},
+	)
+
+	coll := p.client.Database(database).Collection(collection)
+	filterCursor, err := coll.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	var places Places
+	for filterCursor.Next(ctx) {
+		var place Place
+		if err := filterCursor.Decode(&place); err != nil {
+			return nil, err
+		}
+		places = append(places, place)
+	}
+	if err := filterCursor.Err(); err != nil {
+		return nil, err
+	}
 	return &places, nil
 }
@@ -0,0 +1,79 @@
+package data
+
+import (
+	"context"
+	"math"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// RatingStats holds the statistics of the reviews of a place. The histogram counts the reviews per star, from the
+// 1 star reviews at index 0 to the 5 star reviews at index 4, ratings are rounded to the nearest star.
+type RatingStats struct {
+	ReviewCount     int64   `json:"review_count" bson:"review_count,omitempty"`
+	AverageRating   float64 `json:"average_rating" bson:"average_rating,omitempty"`
+	RatingHistogram []int64 `json:"rating_histogram,omitempty" bson:"rating_histogram,omitempty"`
+}
+
+// updateRatingStats recomputes the rating statistics of the place from all its reviews in the reviews collection
+// and sets them on the place document. The statistics are recomputed rather than incremented, so that they are
+// corrected by the next review write should a previous update have failed. The statistics are derived from the
+// reviews, the place version is left as is so that a review doesn't fail the conditional writes of the place owner.
+func (r ReviewModel) updateRatingStats(ctx context.Context, database, collection string, placeID string) error {
+	stats, err := r.ratingStats(ctx, database, collection, placeID)
+	if err != nil {
+		return err
+	}
+	coll := r.client.Database(database).Collection(r.placeCollection)
+	_, err = coll.UpdateOne(ctx, bson.M{"_id": placeID}, bson.D{
+		{Key: "$set", Value: bson.D{
+			{Key: "review_count", Value: stats.ReviewCount},
+			{Key: "average_rating", Value: stats.AverageRating},
+			{Key: "rating_histogram", Value: stats.RatingHistogram},
+		}},
+	})
+	return err
+}
+
+// ratingStats aggregates the rating statistics of the place reviews in the reviews collection, the reviews in the
+// trash are not counted.
+func (r ReviewModel) ratingStats(ctx context.Context, database, collection string, placeID string) (*RatingStats, error) {
+	coll := r.client.Database(database).Collection(collection)
+	// the reviews are grouped by their rating, the distinct ratings are few so they are bucketed into stars here.
+	groupCursor, err := coll.Aggregate(ctx, bson.A{
+		bson.D{{Key: "$match", Value: bson.D{
+			{Key: "place_id", Value: placeID},
+			{Key: "deleted_at", Value: bson.D{{Key: "$exists", Value: false}}},
+		}}},
+		bson.D{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: "$rating"},
+			{Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}},
+		}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var groups []struct {
+		Rating float64 `bson:"_id"`
+		Count  int64   `bson:"count"`
+	}
+	if err := groupCursor.All(ctx, &groups); err != nil {
+		return nil, err
+	}
+
+	stats := RatingStats{RatingHistogram: make([]int64, 5)}
+	var sum float64
+	for _, group := range groups {
+		star := int(math.Round(group.Rating))
+		if star < 1 || star > 5 {
+			continue
+		}
+		stats.RatingHistogram[star-1] += group.Count
+		stats.ReviewCount += group.Count
+		sum += group.Rating * float64(group.Count)
+	}
+	if stats.ReviewCount > 0 {
+		stats.AverageRating = math.Round(sum/float64(stats.ReviewCount)*100) / 100
+	}
+	return &stats, nil
+}
@@ -0,0 +1,44 @@
+package data
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Reply is the public response of the owner of the reviewed place to a review, it is embedded in the review.
+type Reply struct {
+	UserID    string    `json:"user_id,omitempty" bson:"user_id,omitempty"`
+	Text      string    `json:"text,omitempty" bson:"text,omitempty"`
+	CreatedAt time.Time `json:"created_at,omitempty" bson:"created_at,omitempty"`
+	UpdatedAt time.Time `json:"updated_at,omitempty" bson:"updated_at,omitempty"`
+}
+
+// replyUpdate returns the update document setting the reply of a review, a nil reply removes it. The reply is part
+// of the review representation so the review version is incremented.
+func replyUpdate(reply *Reply) bson.D {
+	set := bson.E{Key: "$set", Value: bson.D{{Key: "reply", Value: reply}}}
+	if reply == nil {
+		set = bson.E{Key: "$unset", Value: bson.D{{Key: "reply", Value: ""}}}
+	}
+	return bson.D{set, {Key: "$inc", Value: bson.D{{Key: "version", Value: 1}}}}
+}
+
+// SetReply sets the reply of a specific review document in the reviews collection, takes a context, database name,
+// collection name, the review id and version and the reply, a nil reply deletes the reply. The reply only applies to
+// the version of the review, ErrConflict is returned when the review was updated since it was read.
+func (r ReviewModel) SetReply(ctx context.Context, database, collection string, reviewID string, version int64, reply *Reply) (err error) {
+	defer func() { err = wrapError("ReviewModel.SetReply", err) }()
+	var result *mongo.UpdateResult
+	coll := r.client.Database(database).Collection(collection)
+	result, err = coll.UpdateOne(ctx, versionFilter(reviewID, version), replyUpdate(reply))
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return missingOrConflict(ctx, coll, reviewID)
+	}
+	return nil
+}
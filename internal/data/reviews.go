@@ -2,6 +2,7 @@ package data
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
@@ -18,56 +19,120 @@ type Review struct {
 	TextContent string    `json:"title,omitempty" bson:"title,omitempty"`
 	Rating      float32   `json:"rating,omitempty" bson:"rating,omitempty"`
 	CreatedAt   time.Time `json:"created_at,omitempty" bson:"created_at,omitempty"`
+	UpdatedAt   time.Time `json:"updated_at,omitempty" bson:"updated_at,omitempty"`
+	// Version is incremented on each update of the review.
+	Version int64 `json:"version,omitempty" bson:"version,omitempty"`
+	// Reply is the response of the owner of the place, it is set by SetReply rather than by the review updates.
+	Reply *Reply `json:"reply,omitempty" bson:"reply,omitempty"`
+	// Tombstone is set once the review is moved to the trash.
+	Tombstone `bson:",inline"`
 }
 
 type Reviews []Review
 
+// resetMaintained resets the fields of a new review maintained by the models, the clients can't create a review in the
+// trash or at another version, and the reply is only written by SetReply.
+func (r *Review) resetMaintained() {
+	r.Tombstone = Tombstone{}
+	r.Version = 1
+	r.Reply = nil
+}
+
 type ReviewModel struct {
 	client *mongo.Client
+	// placeCollection is the name of the places collection the rating statistics of the reviewed places are set in,
+	// it is in the same database as the reviews collection.
+	placeCollection string
+	// revisionCollection is the name of the collection the previous versions of the updated reviews are saved in, it
+	// is in the same database as the reviews collection.
+	revisionCollection string
 }
 
-func NewReviewModel(client *mongo.Client) *ReviewModel { return &ReviewModel{client: client} }
+func NewReviewModel(client *mongo.Client, placeCollection, revisionCollection string) *ReviewModel {
+	return &ReviewModel{client: client, placeCollection: placeCollection, revisionCollection: revisionCollection}
+}
 
-// InsertOne inserts a new document to the reviews collection, takes a context, database name, collection name
-// and pointer to place struct object with the data to be inserted.
-func (r ReviewModel) InsertOne(ctx context.Context, database, collection string, review *Review) error {
-	var result *mongo.InsertOneResult
+// CreateIndexes creates the indexes of the reviews collection and the revisions collection, takes a context,
+// database name and reviews collection name. Creating an index that already exists is a no-op, creating the unique
+// index fails when a user already reviewed a place more than once, the duplicates are removed by Duplicates.
+func (r ReviewModel) CreateIndexes(ctx context.Context, database, collection string) error {
 	coll := r.client.Database(database).Collection(collection)
-	result, err := coll.InsertOne(ctx, review)
+	_, err := coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+		// a user reviews a place once, the reviews in the trash count until they are restored or purged.
+		Keys:    bson.D{{Key: "place_id", Value: 1}, {Key: "user_id", Value: 1}},
+		Options: options.Index().SetName("reviews_place_user").SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+	revisions := r.client.Database(database).Collection(r.revisionCollection)
+	_, err = revisions.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "review_id", Value: 1}, {Key: "version", Value: 1}},
+		Options: options.Index().SetName("revisions_review"),
+	})
+	return err
+}
+
+// InsertOne inserts a new document to the reviews collection, takes a context, database name, collection name
+// and pointer to place struct object with the data to be inserted. The rating statistics of the reviewed place are
+// updated on every insert, update and delete of its reviews. ErrValidation is returned when the reviewed place
+// doesn't exist or is in the trash, and ErrConflict when the user already reviewed the place.
+func (r ReviewModel) InsertOne(ctx context.Context, database, collection string, review *Review) (err error) {
+	defer func() { err = wrapError("ReviewModel.InsertOne", err) }()
+	live, err := r.livePlace(ctx, database, review.PlaceID)
 	if err != nil {
 		return err
 	}
-	if result.InsertedID.(string) != review.ID {
-		return ErrNoDocument
+	if !live {
+		return ErrValidation
 	}
-	return nil
+	review.resetMaintained()
+	coll := r.client.Database(database).Collection(collection)
+	if _, err := coll.InsertOne(ctx, review); err != nil {
+		return err
+	}
+	return r.updateRatingStats(ctx, database, collection, review.PlaceID)
 }
 
 // UpdateOne updates a specific review document in the reviews collection, takes a context, database name, collection name
-// and pointer to review struct objet with data to be updated.
-func (r ReviewModel) UpdateOne(ctx context.Context, database, collection string, review *Review) error {
-	var result *mongo.UpdateResult
+// and pointer to review struct objet with data to be updated. The review is the whole updated document, the fields
+// left empty are removed from the document while the place, user and creation time of the review are kept. The update
+// only applies to the version of the review, ErrConflict is returned when the review was updated since it was read.
+// The replaced version of the review is saved as a revision.
+func (r ReviewModel) UpdateOne(ctx context.Context, database, collection string, review *Review) (err error) {
+	defer func() { err = wrapError("ReviewModel.UpdateOne", err) }()
+	var existing Review
 	coll := r.client.Database(database).Collection(collection)
-	result, err := coll.UpdateOne(ctx, bson.M{"_id": review.ID}, bson.D{{Key: "$set", Value: review}})
+	// the review is found and updated in one operation, which returns the review place the rating statistics are
+	// updated for.
+	version := review.Version
+	review.Version++
+	update, err := updateDocument(review, reviewProtectedFields)
 	if err != nil {
 		return err
 	}
-	if result.MatchedCount != 1 || result.UpsertedCount != 1 {
-		return ErrNoDocument
+	result := coll.FindOneAndUpdate(ctx, versionFilter(review.ID, version), update)
+	if err := result.Decode(&existing); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return missingOrConflict(ctx, coll, review.ID)
+		}
+		return err
 	}
-	if result.UpsertedID.(string) != review.ID {
-		return ErrNoDocument
+	// the review is returned as it was before the update.
+	if err := r.saveRevision(ctx, database, &existing); err != nil {
+		return err
 	}
-	return nil
+	return r.updateRatingStats(ctx, database, collection, existing.PlaceID)
 }
 
 // FindOne finds a specific review document in the reviews collection, takes a context, database name, collection name
 // and the document id
-func (r ReviewModel) FindOne(ctx context.Context, database, collection string, reviewID string) (*Review, error) {
+func (r ReviewModel) FindOne(ctx context.Context, database, collection string, reviewID string) (_ *Review, err error) {
+	defer func() { err = wrapError("ReviewModel.FindOne", err) }()
 	var result *mongo.SingleResult
 	var review Review
 	coll := r.client.Database(database).Collection(collection)
-	result = coll.FindOne(ctx, bson.M{"_id": reviewID})
+	result = coll.FindOne(ctx, liveFilter(reviewID))
 	if err := result.Decode(&review); err != nil {
 		return nil, err
 	}
@@ -76,10 +141,11 @@ func (r ReviewModel) FindOne(ctx context.Context, database, collection string, r
 
 // List finds all reviews documents in the reviews collections by place id, takes a context, database name
 // collection name and filter.
-func (r ReviewModel) List(ctx context.Context, database, collection string, placeID string, filter Filter) (*Reviews, error) {
-	opts := options.Find().SetSkip(int64(filter.Skip)).SetLimit(int64(filter.Limit))
+func (r ReviewModel) List(ctx context.Context, database, collection string, placeID string, filter Filter) (_ *Reviews, err error) {
+	defer func() { err = wrapError("ReviewModel.List", err) }()
+	opts := options.Find().SetSkip(int64(filter.Skip)).SetLimit(int64(filter.Limit)).SetSort(filter.sort())
 	coll := r.client.Database(database).Collection(collection)
-	filterCursor, err := coll.Find(ctx, bson.M{"place_id": placeID}, opts)
+	filterCursor, err := coll.Find(ctx, filter.reviewQuery(placeID), opts)
 	if err != nil {
 		return nil, err
 	}
@@ -91,20 +157,97 @@ func (r ReviewModel) List(ctx context.Context, database, collection string, plac
 		}
 		reviews = append(reviews, review)
 	}
+	if err := filterCursor.Err(); err != nil {
+		return nil, err
+	}
 	return &reviews, nil
 }
 
+// Count counts the reviews documents in the reviews collection by place id, takes a context, database name
+// collection name and filter.
+func (r ReviewModel) Count(ctx context.Context, database, collection string, placeID string, filter Filter) (_ int64, err error) {
+	defer func() { err = wrapError("ReviewModel.Count", err) }()
+	coll := r.client.Database(database).Collection(collection)
+	return coll.CountDocuments(ctx, filter.reviewQuery(placeID))
+}
+
 // DeleteOne deletes a specific review document in the reviews collection, takes a context, database name, collection name
-// and document id.
-func (r ReviewModel) DeleteOne(ctx context.Context, database, collection string, reviewID string) error {
-	var result *mongo.DeleteResult
+// document id and version and the id of the user deleting it. The review is moved to the trash and no longer counts in
+// the rating statistics of its place.
+func (r ReviewModel) DeleteOne(ctx context.Context, database, collection string, reviewID string, version int64, deletedBy string) (err error) {
+	defer func() { err = wrapError("ReviewModel.DeleteOne", err) }()
+	var existing Review
+	coll := r.client.Database(database).Collection(collection)
+	result := coll.FindOneAndUpdate(ctx, versionFilter(reviewID, version), tombstoneUpdate(newTombstone(deletedBy)))
+	if err := result.Decode(&existing); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return missingOrConflict(ctx, coll, reviewID)
+		}
+		return err
+	}
+	return r.updateRatingStats(ctx, database, collection, existing.PlaceID)
+}
+
+// Restore restores a specific review document from the trash, takes a context, database name, collection name and
+// document id. The review counts again in the rating statistics of its place, ErrConflict is returned when the
+// place is in the trash.
+func (r ReviewModel) Restore(ctx context.Context, database, collection string, reviewID string) (err error) {
+	defer func() { err = wrapError("ReviewModel.Restore", err) }()
+	var existing Review
 	coll := r.client.Database(database).Collection(collection)
-	result, err := coll.DeleteOne(ctx, bson.M{"_id": reviewID})
+	if err := coll.FindOne(ctx, trashedFilter(reviewID)).Decode(&existing); err != nil {
+		return err
+	}
+	live, err := r.livePlace(ctx, database, existing.PlaceID)
 	if err != nil {
 		return err
 	}
-	if result.DeletedCount != 1 {
-		return ErrNoDocument
+	if !live {
+		return ErrConflict
+	}
+	result := coll.FindOneAndUpdate(ctx, trashedFilter(reviewID), restoreUpdate())
+	if err := result.Decode(&existing); err != nil {
+		return err
+	}
+	return r.updateRatingStats(ctx, database, collection, existing.PlaceID)
+}
+
+// Purge deletes the review documents moved to the trash before the given time for good with their revisions, takes a
+// context, database name, collection name and the time.
+func (r ReviewModel) Purge(ctx context.Context, database, collection string, before time.Time) (_ int64, err error) {
+	defer func() { err = wrapError("ReviewModel.Purge", err) }()
+	return r.purgeReviews(ctx, database, collection, purgeFilter(before))
+}
+
+// purgeReviews deletes the reviews matched by the filter for good with their revisions, the number of deleted reviews
+// is returned.
+func (r ReviewModel) purgeReviews(ctx context.Context, database, collection string, filter bson.D) (int64, error) {
+	coll := r.client.Database(database).Collection(collection)
+	cursor, err := coll.Find(ctx, filter, options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return 0, err
+	}
+	var purged []struct {
+		ID string `bson:"_id"`
+	}
+	if err := cursor.All(ctx, &purged); err != nil {
+		return 0, err
+	}
+	if len(purged) == 0 {
+		return 0, nil
+	}
+	ids := make(bson.A, 0, len(purged))
+	for _, review := range purged {
+		ids = append(ids, review.ID)
+	}
+	// the revisions are deleted first, the reviews left by a failure are purged with their revisions by the next purge.
+	revisions := r.client.Database(database).Collection(r.revisionCollection)
+	if _, err := revisions.DeleteMany(ctx, bson.M{"review_id": bson.M{"$in": ids}}); err != nil {
+		return 0, err
+	}
+	result, err := coll.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return 0, err
 	}
-	return nil
+	return result.DeletedCount, nil
 }
@@ -0,0 +1,74 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Revision is a previous version of a review, it is saved in the revisions collection when the review is updated.
+type Revision struct {
+	ID          string  `json:"id" bson:"_id"`
+	ReviewID    string  `json:"review_id" bson:"review_id"`
+	Version     int64   `json:"version" bson:"version"`
+	TextContent string  `json:"title,omitempty" bson:"title,omitempty"`
+	Rating      float32 `json:"rating,omitempty" bson:"rating,omitempty"`
+	// CreatedAt is the time the review was written with this version, ReplacedAt the time it was updated.
+	CreatedAt  time.Time `json:"created_at" bson:"created_at"`
+	ReplacedAt time.Time `json:"replaced_at" bson:"replaced_at"`
+}
+
+type Revisions []Revision
+
+// newRevision returns the revision of the review replaced by an update at the time.
+func newRevision(review *Review, replacedAt time.Time) Revision {
+	createdAt := review.UpdatedAt
+	if createdAt.IsZero() {
+		createdAt = review.CreatedAt
+	}
+	return Revision{
+		// the revision id is derived from the review version, saving a revision twice replaces it.
+		ID:          fmt.Sprintf("%s@%d", review.ID, review.Version),
+		ReviewID:    review.ID,
+		Version:     review.Version,
+		TextContent: review.TextContent,
+		Rating:      review.Rating,
+		CreatedAt:   createdAt,
+		ReplacedAt:  replacedAt,
+	}
+}
+
+// saveRevision saves the revision of the review replaced by an update in the revisions collection.
+func (r ReviewModel) saveRevision(ctx context.Context, database string, review *Review) error {
+	revision := newRevision(review, time.Now())
+	coll := r.client.Database(database).Collection(r.revisionCollection)
+	_, err := coll.ReplaceOne(ctx, bson.M{"_id": revision.ID}, revision, options.Replace().SetUpsert(true))
+	return err
+}
+
+// History finds the revisions of a specific review in the revisions collection, takes a context, database name,
+// reviews collection name and the review id. The revisions are sorted from the oldest, ErrNoDocument is returned
+// when the review doesn't exist or is in the trash.
+func (r ReviewModel) History(ctx context.Context, database, collection string, reviewID string) (_ *Revisions, err error) {
+	defer func() { err = wrapError("ReviewModel.History", err) }()
+	count, err := r.client.Database(database).Collection(collection).CountDocuments(ctx, liveFilter(reviewID))
+	if err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, ErrNoDocument
+	}
+	coll := r.client.Database(database).Collection(r.revisionCollection)
+	cursor, err := coll.Find(ctx, bson.M{"review_id": reviewID}, options.Find().SetSort(bson.D{{Key: "version", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	revisions := Revisions{}
+	if err := cursor.All(ctx, &revisions); err != nil {
+		return nil, err
+	}
+	return &revisions, nil
+}
@@ -0,0 +1,56 @@
+package data
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Tombstone marks a document moved to the trash, the deleted documents are kept until they are restored or purged
+// once the trash retention elapsed. The tombstone is set by DeleteOne and cleared by Restore, the DeletedAt is nil
+// for the documents not in the trash.
+type Tombstone struct {
+	DeletedAt *time.Time `json:"deleted_at,omitempty" bson:"deleted_at,omitempty"`
+	DeletedBy string     `json:"deleted_by,omitempty" bson:"deleted_by,omitempty"`
+}
+
+// Deleted reports whether the document is in the trash.
+func (t Tombstone) Deleted() bool { return t.DeletedAt != nil }
+
+// newTombstone returns the tombstone of a document deleted now by the user.
+func newTombstone(deletedBy string) Tombstone {
+	now := time.Now()
+	return Tombstone{DeletedAt: &now, DeletedBy: deletedBy}
+}
+
+// liveFilter returns the query of the document with the id, unless it is in the trash.
+func liveFilter(id string) bson.D {
+	return bson.D{{Key: "_id", Value: id}, {Key: "deleted_at", Value: bson.D{{Key: "$exists", Value: false}}}}
+}
+
+// trashedFilter returns the query of the document with the id, only when it is in the trash.
+func trashedFilter(id string) bson.D {
+	return bson.D{{Key: "_id", Value: id}, {Key: "deleted_at", Value: bson.D{{Key: "$exists", Value: true}}}}
+}
+
+// purgeFilter returns the query of the documents moved to the trash before the time.
+func purgeFilter(before time.Time) bson.D {
+	return bson.D{{Key: "deleted_at", Value: bson.D{{Key: "$lt", Value: before}}}}
+}
+
+// tombstoneUpdate returns the update document moving a document to the trash with the tombstone, the deletion is a
+// write of the document so its version is incremented.
+func tombstoneUpdate(tombstone Tombstone) bson.D {
+	return bson.D{
+		{Key: "$set", Value: bson.D{{Key: "deleted_at", Value: tombstone.DeletedAt}, {Key: "deleted_by", Value: tombstone.DeletedBy}}},
+		{Key: "$inc", Value: bson.D{{Key: "version", Value: 1}}},
+	}
+}
+
+// restoreUpdate returns the update document restoring a document from the trash.
+func restoreUpdate() bson.D {
+	return bson.D{
+		{Key: "$unset", Value: bson.D{{Key: "deleted_at", Value: ""}, {Key: "deleted_by", Value: ""}}},
+		{Key: "$inc", Value: bson.D{{Key: "version", Value: 1}}},
+	}
+}
@@ -0,0 +1,124 @@
+package data
+
+import (
+	"context"
+	"reflect"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// The fields of the documents that can't be changed by an update, either because they are immutable once the
+// document is created or because they are maintained by the models.
+var (
+	placeProtectedFields  = []string{"_id", "user_id", "created_at", "review_count", "average_rating", "rating_histogram", "score", "distance", "deleted_at", "deleted_by"}
+	reviewProtectedFields = []string{"_id", "place_id", "user_id", "created_at", "reply", "deleted_at", "deleted_by"}
+)
+
+// updateDocument returns the update document replacing the fields of a stored document with the fields of doc, the
+// fields set in doc are $set and the fields left empty, which are omitted from its encoding, are $unset. The
+// protected fields are left as they are in the stored document.
+func updateDocument(doc interface{}, protected []string) (bson.D, error) {
+	var set bson.M
+	if err := copyDocument(doc, &set); err != nil {
+		return nil, err
+	}
+	isProtected := func(field string) bool {
+		for _, p := range protected {
+			if p == field {
+				return true
+			}
+		}
+		return false
+	}
+
+	setFields, unsetFields := bson.D{}, bson.D{}
+	for _, field := range documentFields(reflect.TypeOf(doc)) {
+		if isProtected(field) {
+			continue
+		}
+		if value, ok := set[field]; ok {
+			setFields = append(setFields, bson.E{Key: field, Value: value})
+		} else {
+			unsetFields = append(unsetFields, bson.E{Key: field, Value: ""})
+		}
+	}
+
+	update := bson.D{}
+	if len(setFields) > 0 {
+		update = append(update, bson.E{Key: "$set", Value: setFields})
+	}
+	if len(unsetFields) > 0 {
+		update = append(update, bson.E{Key: "$unset", Value: unsetFields})
+	}
+	return update, nil
+}
+
+// documentFields returns the names of the top level fields of the documents encoded from the struct type, the
+// fields of the inlined structs included.
+func documentFields(t reflect.Type) []string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	var fields []string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("bson")
+		name, opts, _ := strings.Cut(tag, ",")
+		switch {
+		case name == "-" || !f.IsExported():
+			continue
+		case strings.Contains(opts, "inline"):
+			fields = append(fields, documentFields(f.Type)...)
+			continue
+		case name == "":
+			name = strings.ToLower(f.Name)
+		}
+		fields = append(fields, name)
+	}
+	return fields
+}
+
+// applyUpdate emulates the $set and $unset of the update document over the top level fields of the existing
+// document, the result is decoded into out.
+func applyUpdate(existing interface{}, update bson.D, out interface{}) error {
+	var doc bson.M
+	if err := copyDocument(existing, &doc); err != nil {
+		return err
+	}
+	for _, op := range update {
+		for _, field := range op.Value.(bson.D) {
+			switch op.Key {
+			case "$set":
+				doc[field.Key] = field.Value
+			case "$unset":
+				delete(doc, field.Key)
+			}
+		}
+	}
+	return copyDocument(doc, out)
+}
+
+// versionFilter returns the query of the live document with the id at the given version, so that an update only
+// applies to the version of the document it was made from. The documents written before the versioning have no
+// version.
+func versionFilter(id string, version int64) bson.D {
+	if version == 0 {
+		return append(liveFilter(id), bson.E{Key: "version", Value: bson.D{{Key: "$exists", Value: false}}})
+	}
+	return append(liveFilter(id), bson.E{Key: "version", Value: version})
+}
+
+// missingOrConflict returns the error of a versioned update which matched no document, ErrConflict when the document
+// exists at another version and ErrNoDocument otherwise.
+func missingOrConflict(ctx context.Context, coll *mongo.Collection, id string) error {
+	count, err := coll.CountDocuments(ctx, liveFilter(id))
+	switch {
+	case err != nil:
+		return err
+	case count > 0:
+		return ErrConflict
+	}
+	return ErrNoDocument
+}
@@ -0,0 +1,64 @@
+package logging
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/evansopilo/trouver/internal/data"
+	"github.com/sirupsen/logrus"
+)
+
+// New returns the application logger for the environment, the entries are formatted as JSON in the production and
+// staging environments so that they can be parsed by the log collectors, and as text otherwise. The debug entries
+// are only logged in the development environment.
+func New(env string) *logrus.Logger {
+	logger := logrus.New()
+	logger.SetOutput(os.Stderr)
+	switch env {
+	case "production", "staging":
+		logger.SetFormatter(&logrus.JSONFormatter{TimestampFormat: time.RFC3339Nano})
+	default:
+		logger.SetFormatter(&logrus.TextFormatter{FullTimestamp: true})
+	}
+	if env == "development" {
+		logger.SetLevel(logrus.DebugLevel)
+	}
+	return logger
+}
+
+type contextKey struct{}
+
+// WithEntry returns a copy of the context carrying the log entry.
+func WithEntry(ctx context.Context, entry *logrus.Entry) context.Context {
+	return context.WithValue(ctx, contextKey{}, entry)
+}
+
+// FromContext returns the log entry carried by the context, or an entry of the standard logger when there is none.
+func FromContext(ctx context.Context) *logrus.Entry {
+	if entry, ok := ctx.Value(contextKey{}).(*logrus.Entry); ok {
+		return entry
+	}
+	return logrus.NewEntry(logrus.StandardLogger())
+}
+
+// Observer logs the calls of the model methods with the log entry carried by the context, so that the entries of
+// the database operations share the fields of the request, e.g. the request id. It implements the data.Observer
+// interface.
+type Observer struct{}
+
+func (Observer) Observe(ctx context.Context, model, method string) (context.Context, func(error)) {
+	start := time.Now()
+	return ctx, func(err error) {
+		entry := FromContext(ctx).WithFields(logrus.Fields{
+			"model":    model,
+			"method":   method,
+			"duration": time.Since(start).String(),
+		})
+		if data.IsFailure(err) {
+			entry.WithError(err).Error("database operation failed")
+			return
+		}
+		entry.Debug("database operation")
+	}
+}
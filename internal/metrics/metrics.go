@@ -0,0 +1,107 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"runtime"
+	"strconv"
+	"time"
+
+	"github.com/evansopilo/trouver/internal/data"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "trouver"
+
+// Metrics holds the Prometheus metrics of the HTTP requests and the database operations, registered in their own
+// registry along with the Go runtime and process metrics.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	requests         *prometheus.CounterVec
+	requestDuration  *prometheus.HistogramVec
+	requestsInFlight prometheus.Gauge
+	dbDuration       *prometheus.HistogramVec
+	dbErrors         *prometheus.CounterVec
+}
+
+func New(app, version string) *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "Number of HTTP requests by method, route and status code.",
+		}, []string{"method", "route", "status"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Latency of the HTTP requests by method, route and status code.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		requestsInFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "http_requests_in_flight",
+			Help:      "Number of HTTP requests being served.",
+		}),
+		dbDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "db_operation_duration_seconds",
+			Help:      "Latency of the database operations by model and method.",
+			Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+		}, []string{"model", "method"}),
+		dbErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "db_operation_errors_total",
+			Help:      "Number of failed database operations by model and method.",
+		}, []string{"model", "method"}),
+	}
+
+	buildInfo := prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace:   namespace,
+		Name:        "build_info",
+		Help:        "Build information of the application, the value is always 1.",
+		ConstLabels: prometheus.Labels{"app": app, "version": version, "goversion": runtime.Version()},
+	})
+	buildInfo.Set(1)
+
+	m.registry.MustRegister(
+		m.requests, m.requestDuration, m.requestsInFlight, m.dbDuration, m.dbErrors, buildInfo,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return m
+}
+
+// Handler returns the HTTP handler serving the metrics in the Prometheus exposition format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
+
+// TrackRequest records the start of an HTTP request, the returned function records the end of the request with
+// its method, route pattern and status code. The route pattern rather than the path is used as label, so that the
+// number of series doesn't grow with the ids in the paths.
+func (m *Metrics) TrackRequest() func(method, route string, status int) {
+	start := time.Now()
+	m.requestsInFlight.Inc()
+	return func(method, route string, status int) {
+		m.requestsInFlight.Dec()
+		code := strconv.Itoa(status)
+		m.requests.WithLabelValues(method, route, code).Inc()
+		m.requestDuration.WithLabelValues(method, route, code).Observe(time.Since(start).Seconds())
+	}
+}
+
+// Observe records the latency and the failure of the database operation of the model method, it implements the
+// data.Observer interface.
+func (m *Metrics) Observe(ctx context.Context, model, method string) (context.Context, func(error)) {
+	start := time.Now()
+	return ctx, func(err error) {
+		m.dbDuration.WithLabelValues(model, method).Observe(time.Since(start).Seconds())
+		if data.IsFailure(err) {
+			m.dbErrors.WithLabelValues(model, method).Inc()
+		}
+	}
+}
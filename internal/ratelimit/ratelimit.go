@@ -0,0 +1,104 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Limiter is a per-client token bucket rate limiter. Every client key gets a bucket of burst tokens refilled at
+// rate tokens per second, each request takes one token. Buckets idle for longer than the idle duration are full
+// again and are evicted, so that the memory is bounded by the clients active within the idle duration.
+type Limiter struct {
+	rate  float64
+	burst int
+	idle  time.Duration
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+
+	// now returns the current time, it is replaced in the tests.
+	now func() time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Result is the outcome of a request, with the state of the client bucket after the request.
+type Result struct {
+	Allowed bool
+	// Limit is the bucket size, the number of requests allowed in a burst.
+	Limit int
+	// Remaining is the number of requests the client can still make right away.
+	Remaining int
+	// Reset is the time until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is the time until the next request is allowed, it is zero when the request is allowed.
+	RetryAfter time.Duration
+}
+
+func New(rate float64, burst int) *Limiter {
+	// a bucket is full again once it has been idle for the time it takes to refill all the burst tokens.
+	idle := time.Duration(float64(burst) / rate * float64(time.Second))
+	if idle < time.Minute {
+		idle = time.Minute
+	}
+	return &Limiter{rate: rate, burst: burst, idle: idle, buckets: map[string]*bucket{}, now: time.Now}
+}
+
+// Allow takes a token from the bucket of the client key, the request is allowed when there was a token left.
+func (l *Limiter) Allow(key string) Result {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(l.burst), last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(float64(l.burst), b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+
+	result := Result{Limit: l.burst}
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = l.duration(1 - b.tokens)
+	}
+	result.Remaining = int(b.tokens)
+	result.Reset = l.duration(float64(l.burst) - b.tokens)
+	return result
+}
+
+// Len returns the number of client buckets held by the limiter.
+func (l *Limiter) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.buckets)
+}
+
+// sweep evicts the buckets idle for longer than the idle duration, at most once per idle duration so that the cost
+// of the sweep is spread over the requests. The limiter must be locked.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.idle {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		if now.Sub(b.last) >= l.idle {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// duration returns the time it takes to refill the tokens.
+func (l *Limiter) duration(tokens float64) time.Duration {
+	return time.Duration(tokens / l.rate * float64(time.Second))
+}
@@ -0,0 +1,52 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAllow(t *testing.T) {
+	now := time.Now()
+	l := New(1, 2)
+	l.now = func() time.Time { return now }
+
+	for i, want := range []bool{true, true, false} {
+		if got := l.Allow("client"); got.Allowed != want {
+			t.Fatalf("request %d: got allowed %v; want %v", i, got.Allowed, want)
+		}
+	}
+
+	result := l.Allow("client")
+	if result.Remaining != 0 || result.RetryAfter != time.Second || result.Reset != 2*time.Second {
+		t.Errorf("unexpected result %+v", result)
+	}
+	if !l.Allow("other").Allowed {
+		t.Error("other client: got request denied; want every client to have its own bucket")
+	}
+
+	now = now.Add(time.Second)
+	if result := l.Allow("client"); !result.Allowed || result.Remaining != 0 {
+		t.Errorf("after refill: unexpected result %+v", result)
+	}
+}
+
+func TestSweep(t *testing.T) {
+	now := time.Now()
+	l := New(1, 2)
+	l.now = func() time.Time { return now }
+
+	l.Allow("a")
+	l.Allow("b")
+	now = now.Add(30 * time.Second)
+	l.Allow("c")
+	if l.Len() != 3 {
+		t.Fatalf("got %d buckets; want 3", l.Len())
+	}
+
+	// a and b have been idle for the idle duration, c only for half of it.
+	now = now.Add(30 * time.Second)
+	l.Allow("d")
+	if l.Len() != 2 {
+		t.Errorf("got %d buckets; want the idle buckets to be evicted", l.Len())
+	}
+}
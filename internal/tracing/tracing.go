@@ -0,0 +1,106 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/evansopilo/trouver/internal/data"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentation is the name of the tracer of the application spans.
+const instrumentation = "github.com/evansopilo/trouver"
+
+// Options holds the settings of the trace exporter and of the resource the spans are attributed to.
+type Options struct {
+	// Exporter is either "none" (the default) to disable the export of the spans, "stdout" to print them or "otlp"
+	// to send them to an OpenTelemetry collector over OTLP/HTTP.
+	Exporter string
+	// Endpoint is the host and port of the collector, and Insecure disables TLS to reach a local collector.
+	Endpoint string
+	Insecure bool
+	// SampleRatio is the ratio of the traces started by the application which are sampled, the traces started by
+	// the callers follow their sampling decision.
+	SampleRatio float64
+
+	ServiceName    string
+	ServiceVersion string
+	Environment    string
+}
+
+// Setup installs the W3C trace context and baggage propagators and the tracer provider exporting the spans, as
+// the global OpenTelemetry propagator and tracer provider. The returned function flushes the pending spans and
+// stops the exporter, it is a no-op when the export is disabled.
+func Setup(ctx context.Context, opts Options) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch opts.Exporter {
+	case "", "none":
+		return func(context.Context) error { return nil }, nil
+	case "stdout":
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case "otlp":
+		clientOpts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(opts.Endpoint)}
+		if opts.Insecure {
+			clientOpts = append(clientOpts, otlptracehttp.WithInsecure())
+		}
+		exporter, err = otlptracehttp.New(ctx, clientOpts...)
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q", opts.Exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.ServiceNameKey.String(opts.ServiceName),
+		semconv.ServiceVersionKey.String(opts.ServiceVersion),
+		semconv.DeploymentEnvironmentKey.String(opts.Environment),
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(opts.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// Tracer returns the tracer of the application spans from the global tracer provider.
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentation)
+}
+
+// Observer traces the calls of the model methods, each call is a child span of the span carried by the context,
+// e.g. the span of the request. It implements the data.Observer interface.
+type Observer struct{}
+
+func (Observer) Observe(ctx context.Context, model, method string) (context.Context, func(error)) {
+	ctx, span := Tracer().Start(ctx, model+"."+method,
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(attribute.String("model", model), attribute.String("model.method", method)),
+	)
+	return ctx, func(err error) {
+		if data.IsFailure(err) {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+}
@@ -1,25 +1,108 @@
 package validator
 
 import (
+	"errors"
+	"fmt"
+	"strings"
+
 	"github.com/evansopilo/trouver/internal/data"
 	validation "github.com/go-ozzo/ozzo-validation/v4"
 	"github.com/go-ozzo/ozzo-validation/v4/is"
 )
 
+// ValidatePlace validates a place, the location address and geo point are validated as nested fields.
 func ValidatePlace(place *data.Place) error {
-	return validation.ValidateStruct(&place,
+	return validation.ValidateStruct(place,
 		validation.Field(&place.Title, validation.Required, validation.Length(0, 150)),
 		validation.Field(&place.Description, validation.Required, validation.Length(0, 150)),
-		validation.Field(&place.Categories, validation.Length(0, 5)),
+		validation.Field(&place.Categories, validation.Length(0, 5), validation.Each(validation.Required, validation.Length(0, 50))),
 		validation.Field(&place.ImageURL, is.URL),
 		validation.Field(&place.PhoneNumber, validation.Length(0, 20)),
 		validation.Field(&place.Email, is.Email),
-		validation.Field(&place.Location.Address.Street1, validation.Length(0, 30)),
-		validation.Field(&place.Location.Address.City, validation.Length(0, 30)),
-		validation.Field(&place.Location.Address.State, validation.Length(0, 30)),
-		validation.Field(&place.Location.Address.ZipCode, validation.Length(0, 30)),
-		validation.Field(&place.Location.Geo.Type, validation.Length(0, 30)),
-		validation.Field(&place.Location.Geo.Coordinates[0], is.Longitude),
-		validation.Field(&place.Location.Geo.Coordinates[1], is.Latitude),
+		validation.Field(&place.Location, validation.By(func(interface{}) error {
+			return validation.ValidateStruct(&place.Location,
+				validation.Field(&place.Location.Address, validation.By(func(interface{}) error {
+					return validateAddress(&place.Location.Address)
+				})),
+				validation.Field(&place.Location.Geo, validation.By(func(interface{}) error {
+					return validateGeo(&place.Location.Geo)
+				})),
+			)
+		})),
+	)
+}
+
+func validateAddress(address *data.Address) error {
+	return validation.ValidateStruct(address,
+		validation.Field(&address.Street1, validation.Length(0, 30)),
+		validation.Field(&address.City, validation.Length(0, 30)),
+		validation.Field(&address.State, validation.Length(0, 30)),
+		validation.Field(&address.ZipCode, validation.Length(0, 30)),
+	)
+}
+
+// validateGeo validates the geo point, which is optional but when set must be a GeoJSON point with the longitude
+// and latitude coordinates.
+func validateGeo(geo *data.Geo) error {
+	if geo.IsZero() {
+		return nil
+	}
+	return validation.ValidateStruct(geo,
+		validation.Field(&geo.Type, validation.Required, validation.In("Point").Error("must be Point")),
+		validation.Field(&geo.Coordinates, validation.Required, validation.Length(2, 2).Error("must be [longitude, latitude]"),
+			validation.By(func(interface{}) error {
+				if len(geo.Coordinates) != 2 {
+					return nil
+				}
+				if geo.Coordinates[0] < -180 || geo.Coordinates[0] > 180 {
+					return errors.New("longitude must be between -180 and 180")
+				}
+				if geo.Coordinates[1] < -90 || geo.Coordinates[1] > 90 {
+					return errors.New("latitude must be between -90 and 90")
+				}
+				return nil
+			})),
+	)
+}
+
+// ValidateReview validates a review, the reviewed place must exist and the rating must be between 1 and 5 stars.
+func ValidateReview(review *data.Review, placeExists bool) error {
+	return validation.ValidateStruct(review,
+		validation.Field(&review.PlaceID, validation.Required, validation.By(func(interface{}) error {
+			if !placeExists {
+				return errors.New("place does not exist")
+			}
+			return nil
+		})),
+		validation.Field(&review.TextContent, validation.Length(0, 2000)),
+		validation.Field(&review.Rating, validation.Required, validation.Min(float32(1)), validation.Max(float32(5))),
+	)
+}
+
+// ValidateReply validates the reply of the owner of a place to a review.
+func ValidateReply(reply *data.Reply) error {
+	return validation.ValidateStruct(reply,
+		validation.Field(&reply.Text, validation.Required, validation.Length(0, 2000)),
+	)
+}
+
+// ValidateFilter validates the filter of a listing, the sort fields must be in the sort safelist and can't be used
+// together with a cursor.
+func ValidateFilter(filter *data.Filter, sortSafelist []string) error {
+	return validation.ValidateStruct(filter,
+		validation.Field(&filter.MinRating, validation.Min(0.0), validation.Max(5.0)),
+		validation.Field(&filter.CreatedBefore, validation.When(!filter.CreatedAfter.IsZero() && !filter.CreatedBefore.IsZero(),
+			validation.Min(filter.CreatedAfter).Exclusive().Error("must be after created_after"))),
+		validation.Field(&filter.Sort,
+			validation.When(filter.After != nil, validation.Empty.Error("can't be used together with cursor")),
+			validation.Each(validation.By(func(value interface{}) error {
+				field := strings.TrimPrefix(value.(string), "-")
+				for _, safe := range sortSafelist {
+					if field == safe {
+						return nil
+					}
+				}
+				return fmt.Errorf("unknown sort field %q", field)
+			}))),
 	)
 }
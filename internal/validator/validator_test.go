@@ -0,0 +1,128 @@
+package validator
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/evansopilo/trouver/internal/data"
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+func TestValidatePlace(t *testing.T) {
+	valid := func() *data.Place {
+		place := &data.Place{Title: "Java House", Description: "Coffee and breakfast.", Categories: []string{"coffee"}}
+		place.Location.Geo = data.Geo{Type: "Point", Coordinates: []float64{36.8219, -1.2921}}
+		return place
+	}
+
+	tests := []struct {
+		name   string
+		modify func(place *data.Place)
+		want   []string
+	}{
+		{"valid", func(place *data.Place) {}, nil},
+		{"without location", func(place *data.Place) { place.Location.Geo = data.Geo{} }, nil},
+		{"missing title", func(place *data.Place) { place.Title = "" }, []string{"title"}},
+		{"long description", func(place *data.Place) { place.Description = strings.Repeat("a", 151) }, []string{"description"}},
+		{"empty category", func(place *data.Place) { place.Categories = []string{"coffee", ""} }, []string{"categories.1"}},
+		{"too many categories", func(place *data.Place) { place.Categories = []string{"a", "b", "c", "d", "e", "f"} }, []string{"categories"}},
+		{"invalid email and url", func(place *data.Place) { place.Email, place.ImageURL = "java", "not a url" }, []string{"email", "image_url"}},
+		{"long city", func(place *data.Place) { place.Location.Address.City = strings.Repeat("a", 31) }, []string{"location.address.city"}},
+		{"empty coordinates", func(place *data.Place) { place.Location.Geo.Coordinates = nil }, []string{"location.geo.coordinates"}},
+		{"one coordinate", func(place *data.Place) { place.Location.Geo.Coordinates = []float64{36.8} }, []string{"location.geo.coordinates"}},
+		{"longitude out of range", func(place *data.Place) { place.Location.Geo.Coordinates = []float64{200, 0} }, []string{"location.geo.coordinates"}},
+		{"latitude out of range", func(place *data.Place) { place.Location.Geo.Coordinates = []float64{0, -91} }, []string{"location.geo.coordinates"}},
+		{"not a point", func(place *data.Place) { place.Location.Geo.Type = "Polygon" }, []string{"location.geo.type"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			place := valid()
+			tt.modify(place)
+			if got := errorFields(t, ValidatePlace(place)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got errors for %v; want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateReview(t *testing.T) {
+	tests := []struct {
+		name        string
+		review      data.Review
+		placeExists bool
+		want        []string
+	}{
+		{"valid", data.Review{PlaceID: "place-1", Rating: 4, TextContent: "Great coffee."}, true, nil},
+		{"missing place", data.Review{PlaceID: "place-1", Rating: 4}, false, []string{"place_id"}},
+		{"missing place id", data.Review{Rating: 4}, true, []string{"place_id"}},
+		{"missing rating", data.Review{PlaceID: "place-1"}, true, []string{"rating"}},
+		{"rating too low", data.Review{PlaceID: "place-1", Rating: 0.5}, true, []string{"rating"}},
+		{"rating too high", data.Review{PlaceID: "place-1", Rating: 5.5}, true, []string{"rating"}},
+		{"long text", data.Review{PlaceID: "place-1", Rating: 4, TextContent: strings.Repeat("a", 2001)}, true, []string{"title"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := errorFields(t, ValidateReview(&tt.review, tt.placeExists)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got errors for %v; want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateReply(t *testing.T) {
+	if err := ValidateReply(&data.Reply{Text: "Thank you!"}); err != nil {
+		t.Errorf("got error %v; want none", err)
+	}
+	if got := errorFields(t, ValidateReply(&data.Reply{})); !reflect.DeepEqual(got, []string{"text"}) {
+		t.Errorf("got errors for %v; want [text]", got)
+	}
+}
+
+func TestValidateFilter(t *testing.T) {
+	safelist := []string{"created_at", "title"}
+	tests := []struct {
+		name   string
+		filter data.Filter
+		want   []string
+	}{
+		{"valid", data.Filter{MinRating: 4, Sort: []string{"-created_at", "title"}}, nil},
+		{"unknown sort field", data.Filter{Sort: []string{"title", "-owner"}}, []string{"sort.1"}},
+		{"sort with cursor", data.Filter{Sort: []string{"title"}, After: &data.Cursor{ID: "place-1"}}, []string{"sort"}},
+		{"min rating too high", data.Filter{MinRating: 5.5}, []string{"min_rating"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := errorFields(t, ValidateFilter(&tt.filter, safelist)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got errors for %v; want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// errorFields returns the sorted paths of the fields failing the validation, the nested fields are joined by dots.
+func errorFields(t *testing.T, err error) []string {
+	t.Helper()
+	if err == nil {
+		return nil
+	}
+	errs, ok := err.(validation.Errors)
+	if !ok {
+		t.Fatalf("got error %v; want validation errors", err)
+	}
+	var fields []string
+	var walk func(prefix string, errs validation.Errors)
+	walk = func(prefix string, errs validation.Errors) {
+		for field, err := range errs {
+			if nested, ok := err.(validation.Errors); ok {
+				walk(prefix+field+".", nested)
+				continue
+			}
+			fields = append(fields, prefix+field)
+		}
+	}
+	walk("", errs)
+	sort.Strings(fields)
+	return fields
+}